@@ -0,0 +1,251 @@
+package antpost
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AbortCondition decides if a run should stop early. Check is told of each
+// iteration in turn, never concurrently, and returns why to abort, or "".
+type AbortCondition interface {
+	Check(it *Iteration) string
+}
+
+// AbortError is why an Abort stopped a run.
+type AbortError struct {
+	Reason  string
+	At      time.Time
+	Elapsed time.Duration // since the first iteration started
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("aborted after %v: %s", e.Elapsed, e.Reason)
+}
+
+// Abort watches a run and cancels it once any of its conditions trips.
+type Abort struct {
+	lock       sync.Mutex
+	conditions []AbortCondition
+	ctx        context.Context
+	cancel     context.CancelFunc
+	begin      time.Time
+	err        *AbortError
+}
+
+// WithAbort returns a copy of ctx that is done once any condition trips,
+// and adds the Abort watching for that to ctx. The Report of a run started
+// with the returned context says why and when it stopped.
+func WithAbort(ctx context.Context, conditions ...AbortCondition) (context.Context, *Abort) {
+	ctx, cancel := context.WithCancel(ctx)
+	a := &Abort{conditions: conditions, ctx: ctx, cancel: cancel}
+	return WithWatcher(ctx, a), a
+}
+
+func (a *Abort) Watch(it *Iteration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Once done otherwise, the run was not aborted.
+	if a.err != nil || a.ctx.Err() != nil {
+		return
+	}
+
+	if a.begin.IsZero() || it.Start.Before(a.begin) {
+		a.begin = it.Start
+	}
+
+	for _, c := range a.conditions {
+		if reason := c.Check(it); reason != "" {
+			now := time.Now()
+			a.err = &AbortError{reason, now, now.Sub(a.begin)}
+			a.cancel()
+			return
+		}
+	}
+}
+
+// Err is why the run was aborted, or nil.
+func (a *Abort) Err() *AbortError {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.err
+}
+
+// Stop releases the context of WithAbort, as its cancel func would.
+func (a *Abort) Stop() {
+	a.cancel()
+}
+
+// abortFrom is why the first Abort watching runs with ctx that tripped
+// did, or nil.
+func abortFrom(ctx context.Context) *AbortError {
+	for _, w := range watchersFrom(ctx) {
+		if a, ok := w.(*Abort); ok {
+			if err := a.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AbortOnErrorRate trips when more than rate, in [0, 1], of the iterations
+// that ended in the last window were not ResultOK. It waits for at least
+// min iterations in the window.
+func AbortOnErrorRate(rate float64, window time.Duration, min int) AbortCondition {
+	return &errorRateAbort{rate, slidingWindow{d: window}, min, 0}
+}
+
+// AbortOnConnectFails trips after n ResultConnectFail in a row.
+func AbortOnConnectFails(n int) AbortCondition {
+	return &connectFailsAbort{n, 0}
+}
+
+// AbortOnPercentile trips when the quantile q, in [0, 1], of the times of
+// the iterations that ended in the last window is over limit, taking the
+// quantile as the time at rank ceil(q*(n-1)) of the n in the window. It
+// waits for at least min iterations in the window.
+func AbortOnPercentile(q float64, limit time.Duration, window time.Duration, min int) AbortCondition {
+	return &percentileAbort{q, limit, slidingWindow{d: window}, min, 0}
+}
+
+type windowEntry struct {
+	end  time.Time
+	time time.Duration
+	ok   bool
+}
+
+// slidingWindow keeps the iterations that ended within d of the last one,
+// in the order they were added, so each is added and evicted once.
+type slidingWindow struct {
+	d       time.Duration
+	entries []windowEntry
+	head    int
+}
+
+// add adds it, calling evict for each entry that falls out of the window.
+func (w *slidingWindow) add(it *Iteration, evict func(e windowEntry)) {
+	w.entries = append(w.entries, windowEntry{it.End, it.Time(), it.Result == ResultOK})
+	w.prune(it.End, evict)
+}
+
+// prune evicts the entries that ended more than d before now, calling evict,
+// if not nil, for each.
+func (w *slidingWindow) prune(now time.Time, evict func(e windowEntry)) {
+	for w.head < len(w.entries) && now.Sub(w.entries[w.head].end) > w.d {
+		if evict != nil {
+			evict(w.entries[w.head])
+		}
+
+		w.head++
+	}
+
+	if w.head > 0 && w.head*2 >= len(w.entries) {
+		w.entries = append(w.entries[:0], w.entries[w.head:]...)
+		w.head = 0
+	}
+}
+
+func (w *slidingWindow) len() int {
+	return len(w.entries) - w.head
+}
+
+// window is the entries in the window, oldest first.
+func (w *slidingWindow) window() []windowEntry {
+	return w.entries[w.head:]
+}
+
+type errorRateAbort struct {
+	rate   float64
+	window slidingWindow
+	min    int
+	errors int
+}
+
+func (e *errorRateAbort) Check(it *Iteration) string {
+	if it.Result != ResultOK {
+		e.errors++
+	}
+
+	e.window.add(it, func(w windowEntry) {
+		if !w.ok {
+			e.errors--
+		}
+	})
+
+	n := e.window.len()
+	if n < e.min || n == 0 {
+		return ""
+	}
+
+	if rate := float64(e.errors) / float64(n); rate > e.rate {
+		return fmt.Sprintf("error rate %.2f%% of %d in %v is over %.2f%%", rate*100, n, e.window.d, e.rate*100)
+	}
+
+	return ""
+}
+
+type connectFailsAbort struct {
+	n    int
+	fail int
+}
+
+func (c *connectFailsAbort) Check(it *Iteration) string {
+	if it.Result != ResultConnectFail {
+		c.fail = 0
+		return ""
+	}
+
+	c.fail++
+	if c.fail >= c.n {
+		return fmt.Sprintf("%d connect fails in a row", c.fail)
+	}
+
+	return ""
+}
+
+type percentileAbort struct {
+	q      float64
+	limit  time.Duration
+	window slidingWindow
+	min    int
+	slow   int // in the window, over limit
+}
+
+// Check counts the iterations over limit, so only sorts the window once it
+// trips, for the time to report.
+func (p *percentileAbort) Check(it *Iteration) string {
+	if it.Time() > p.limit {
+		p.slow++
+	}
+
+	p.window.add(it, func(w windowEntry) {
+		if w.time > p.limit {
+			p.slow--
+		}
+	})
+
+	n := p.window.len()
+	if n < p.min || n == 0 {
+		return ""
+	}
+
+	rank := int(math.Ceil(p.q * float64(n-1)))
+	if rank < n-p.slow {
+		return ""
+	}
+
+	d := make([]float64, 0, n)
+	for _, e := range p.window.entries[p.window.head:] {
+		d = append(d, float64(e.time))
+	}
+
+	sort.Float64s(d)
+	v := time.Duration(d[rank])
+	return fmt.Sprintf("%s %v of %d in %v is over %v", quantileName(p.q), v, n, p.window.d, p.limit)
+}
@@ -0,0 +1,108 @@
+package antpost
+
+import "testing"
+
+import (
+	"context"
+	"time"
+)
+
+type failDrone struct {
+	result DroneResult
+}
+
+func (f *failDrone) Run(context *Context) DroneResult {
+	time.Sleep(time.Millisecond)
+	return f.result
+}
+
+func (f *failDrone) Next() Drone {
+	return f
+}
+
+func TestAbortConditions(t *testing.T) {
+	now := time.Now()
+	it := func(result DroneResult, d time.Duration) *Iteration {
+		now = now.Add(10 * time.Millisecond)
+		return &Iteration{Result: result, Start: now.Add(-d), End: now}
+	}
+
+	c := AbortOnConnectFails(3)
+	for i, result := range []DroneResult{ResultConnectFail, ResultConnectFail, ResultOK, ResultConnectFail, ResultConnectFail} {
+		if reason := c.Check(it(result, 0)); reason != "" {
+			t.Errorf("AbortOnConnectFails(3) tripped at %d: %s", i, reason)
+		}
+	}
+
+	if c.Check(it(ResultConnectFail, 0)) == "" {
+		t.Errorf("AbortOnConnectFails(3) should trip on 3 fails in a row")
+	}
+
+	e := AbortOnErrorRate(0.5, 50*time.Millisecond, 4)
+	for i, result := range []DroneResult{ResultResponseBroken, ResultResponseBroken, ResultOK, ResultOK, ResultOK, ResultOK, ResultOK, ResultOK, ResultResponseBroken, ResultResponseBroken, ResultResponseBroken} {
+		if reason := e.Check(it(result, 0)); reason != "" {
+			t.Errorf("AbortOnErrorRate(0.5) tripped at %d: %s", i, reason)
+		}
+	}
+
+	if e.Check(it(ResultResponseBroken, 0)) == "" {
+		t.Errorf("AbortOnErrorRate(0.5) should trip on 4 of 6 errors")
+	}
+
+	p := AbortOnPercentile(0.99, 100*time.Millisecond, time.Second, 10)
+	for i := 0; i < 20; i++ {
+		if reason := p.Check(it(ResultOK, 10*time.Millisecond)); reason != "" {
+			t.Errorf("AbortOnPercentile() tripped at %d: %s", i, reason)
+		}
+	}
+
+	if reason := p.Check(it(ResultOK, time.Second)); reason == "" {
+		t.Errorf("AbortOnPercentile() should trip on a slow P99")
+	}
+
+	p = AbortOnPercentile(0.5, 100*time.Millisecond, 50*time.Millisecond, 2)
+	for i := 0; i < 20; i++ {
+		d := time.Millisecond
+		if i < 3 {
+			d = time.Second
+		}
+
+		if reason := p.Check(it(ResultOK, d)); (reason != "") != (i >= 1 && i < 6) {
+			t.Errorf("AbortOnPercentile() over a sliding window at %d: %q", i, reason)
+		}
+	}
+}
+
+func TestWithAbort(t *testing.T) {
+	ctx, a := WithAbort(context.Background(), AbortOnConnectFails(5))
+	defer a.Stop()
+
+	begin := time.Now()
+	c := RunContext(ctx, &failDrone{ResultConnectFail}, 4, 0, 5*time.Second)
+	if time.Now().Sub(begin) > time.Second {
+		t.Errorf("RunContext() with abort did not stop early")
+	}
+
+	r := c.Report()
+	if !r.Canceled || r.Abort == nil || a.Err() == nil || r.Abort.Reason != a.Err().Reason {
+		t.Errorf("RunContext() with abort report: %v, %v", r.Canceled, r.Abort)
+		return
+	}
+
+	j, err := r.MarshalJSON()
+	if err != nil {
+		t.Errorf("Report.MarshalJSON() failed: %v", err)
+		return
+	}
+
+	u := new(Report)
+	if err := u.UnmarshalJSON(j); err != nil || u.Abort == nil || u.Abort.Reason != r.Abort.Reason || u.Abort.Elapsed != r.Abort.Elapsed {
+		t.Errorf("Report.UnmarshalJSON() abort: %v, %v", err, u.Abort)
+	}
+
+	ctx, a = WithAbort(context.Background(), AbortOnConnectFails(5))
+	defer a.Stop()
+	if r := RunContext(ctx, &failDrone{ResultOK}, 2, 10, 0).Report(); r.Canceled || r.Abort != nil {
+		t.Errorf("RunContext() without abort report: %v, %v", r.Canceled, r.Abort)
+	}
+}
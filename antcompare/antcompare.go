@@ -0,0 +1,71 @@
+// antcompare compares a report saved as JSON with a baseline one, and exits
+// with 1 when any metric regressed past its fail threshold.
+//
+//	antcompare [flags] baseline.json current.json
+package main
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	t := antpost.DefaultCompareThresholds
+	flag.Float64Var(&t.RelativeWarn, "warn", t.RelativeWarn, "relative change that warns, 0.05 for 5%")
+	flag.Float64Var(&t.RelativeFail, "fail", t.RelativeFail, "relative change that fails")
+	flag.Float64Var(&t.PointsWarn, "points-warn", t.PointsWarn, "change of rates and percents, in percentage points, that warns")
+	flag.Float64Var(&t.PointsFail, "points-fail", t.PointsFail, "change of rates and percents, in percentage points, that fails")
+	all := flag.Bool("all", false, "list passing metrics too")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] baseline.json current.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	baseline, err := load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	current, err := load(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	c := antpost.CompareReportsWith(baseline, current, t)
+	if *all {
+		fmt.Print(c)
+	} else {
+		fmt.Print(c.Filter(antpost.CompareWarn))
+	}
+
+	if c.Regressed() {
+		os.Exit(1)
+	}
+}
+
+func load(path string) (*antpost.Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(antpost.Report)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return r, nil
+}
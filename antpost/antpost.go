@@ -0,0 +1,218 @@
+// antpost runs an HTTP load test from the command line and prints its
+// report, as text or as JSON for antcompare.
+//
+//	antpost [flags] url
+//	antpost [flags] -scenario flow.yaml
+//	antpost [flags] -replay site.har
+//	antpost [flags] -replay access.log url
+package main
+
+import (
+	"github.com/benbearchen/antpost"
+	"github.com/benbearchen/antpost/drones"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+type headers http.Header
+
+func (h headers) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headers) Set(s string) error {
+	p := strings.Index(s, ":")
+	if p <= 0 {
+		return fmt.Errorf("header %q is not `Name: value`", s)
+	}
+
+	http.Header(h).Add(strings.TrimSpace(s[:p]), strings.TrimSpace(s[p+1:]))
+	return nil
+}
+
+func main() {
+	header := make(headers)
+	method := flag.String("X", "GET", "request method")
+	flag.Var(header, "H", "request header as `Name: value`, repeatable")
+	body := flag.String("body", "", "`file` of the request body")
+	status := flag.String("status", "2xx,3xx", "status codes that pass, like 200,3xx,500-503, or empty or any to pass any")
+	scenario := flag.String("scenario", "", "YAML or JSON `file` of a scenario to run instead of url")
+	replay := flag.String("replay", "", "HAR `file`, named *.har, or access log to replay against url")
+	speed := flag.Float64("speed", 0, "replay at this times the recorded timing, 0 for full speed")
+	feed := flag.String("feed", "", "CSV or JSONL `file` of records to fill ${field} in the url, headers and body")
+	feedMode := flag.String("feed-mode", "sequential", "how records are fed: sequential, random or unique")
+	perWorker := flag.Bool("feed-per-worker", false, "feed every worker all records, instead of sharing them")
+	transport := new(drones.HttpTransport)
+	flag.BoolVar(&transport.PerWorker, "conns-per-worker", false, "give every worker its own connections, instead of sharing them")
+	flag.BoolVar(&transport.DisableKeepAlive, "no-keepalive", false, "make a new connection for every request")
+	flag.IntVar(&transport.MaxConnsPerHost, "max-conns", 0, "connections per host of each pool, 0 for no limit")
+	flag.DurationVar(&transport.DialTimeout, "dial-timeout", 0, "timeout to connect, 0 for none")
+	flag.DurationVar(&transport.TLSHandshakeTimeout, "tls-timeout", 0, "timeout of the TLS handshake, 0 for none")
+	flag.DurationVar(&transport.ResponseTimeout, "response-timeout", 0, "timeout to the response header, 0 for none")
+	flag.DurationVar(&transport.Timeout, "timeout", 0, "timeout of each request, 0 for none")
+	flag.BoolVar(&transport.HTTP2, "http2", false, "negotiate HTTP/2 over TLS")
+	flag.BoolVar(&transport.InsecureSkipVerify, "k", false, "skip verifying TLS certificates")
+	concurrency := flag.Int("c", 1, "concurrent workers")
+	count := flag.Int("n", 0, "total requests, 0 for no limit")
+	d := flag.Duration("d", 0, "run duration, 0 for no limit")
+	rate := flag.Float64("rate", 0, "requests per second issued open-loop, 0 to run closed-loop")
+	format := flag.String("format", "text", "report format, text or json")
+	output := flag.String("o", "", "`file` to save the report to, instead of stdout")
+	progress := flag.Duration("progress", 5*time.Second, "how often to print progress to stderr, 0 for never")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] url\n       %s [flags] -scenario file\n       %s [flags] -replay file [url]\n", os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() > 1 || *concurrency <= 0 || (*format != "text" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *count <= 0 && *d <= 0 && *replay == "" {
+		fmt.Fprintln(os.Stderr, "either -n or -d is required")
+		os.Exit(2)
+	}
+
+	var feeder *drones.Feeder
+	if *feed != "" {
+		mode, err := drones.ParseFeedMode(*feedMode)
+		if err == nil && (*scenario != "" || *replay != "") {
+			err = fmt.Errorf("-feed does not work with -scenario or -replay")
+		} else if err == nil {
+			feeder, err = drones.LoadFeeder(*feed, mode, *perWorker)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	checks := []*drones.HttpCheck{{Status: *status}}
+	if *status == "" {
+		checks[0].Status = "any"
+	}
+
+	drone, err := newDrone(*scenario, *replay, *speed, *method, header, *body, feeder, transport, checks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *progress > 0 {
+		p := antpost.NewProgress(*progress)
+		ctx = antpost.WithWatcher(ctx, p)
+		go func() {
+			for r := range p.Subscribe(ctx, *progress) {
+				fmt.Fprintln(os.Stderr, r)
+			}
+		}()
+	}
+
+	var c *antpost.Context
+	if *rate > 0 {
+		c = antpost.RunRateContext(ctx, drone, *concurrency, *rate, *count, *d)
+	} else if *count > 0 {
+		c = antpost.RunTotalContext(ctx, drone, *concurrency, *count, *d)
+	} else {
+		c = antpost.RunContext(ctx, drone, *concurrency, 0, *d)
+	}
+
+	stop()
+	if err := save(c.Report(), *format, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newDrone(scenario, replay string, speed float64, method string, header headers, body string, feeder *drones.Feeder, transport *drones.HttpTransport, checks []*drones.HttpCheck) (antpost.Drone, error) {
+	url := flag.Arg(0)
+	if scenario != "" {
+		s, err := drones.LoadScenario(scenario)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Transport = transport
+		return s.Drone()
+	} else if replay != "" {
+		var entries []*drones.ReplayEntry
+		var err error
+		if strings.HasSuffix(strings.ToLower(replay), ".har") {
+			entries, err = drones.LoadHAR(replay)
+		} else if url == "" {
+			err = fmt.Errorf("replaying an access log needs the url to send it to")
+		} else {
+			entries, err = drones.LoadAccessLog(replay, url)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			e.Req.Transport = transport
+			e.Req.Checks = checks
+		}
+
+		return drones.NewReplayDrone(entries, speed), nil
+	} else if url == "" {
+		return nil, fmt.Errorf("no url to run")
+	}
+
+	h := &drones.HttpReq{Url: url, Method: strings.ToUpper(method), Transport: transport, Checks: checks}
+	if len(header) > 0 {
+		h.Header = http.Header(header)
+	}
+
+	if body != "" {
+		data, err := ioutil.ReadFile(body)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Data = data
+	}
+
+	if feeder != nil {
+		return drones.NewHttpFeedDrone(h, feeder), nil
+	}
+
+	return drones.NewHttpDrone(h), nil
+}
+
+func save(r *antpost.Report, format, path string) error {
+	var data []byte
+	if format == "json" {
+		var err error
+		data, err = json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		data = append(data, '\n')
+	} else {
+		data = []byte(r.String())
+	}
+
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
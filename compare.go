@@ -0,0 +1,189 @@
+package antpost
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type CompareStatus int
+
+const (
+	ComparePass CompareStatus = iota
+	CompareWarn CompareStatus = iota
+	CompareFail CompareStatus = iota
+)
+
+func (s CompareStatus) String() string {
+	switch s {
+	case ComparePass:
+		return "pass"
+	case CompareWarn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// CompareThresholds says how much worse a metric may get before it warns or
+// fails. Relative ones are fractions of the baseline, for durations, rates,
+// counts and values. Points are absolute percentage points, for fractions
+// and percents.
+type CompareThresholds struct {
+	RelativeWarn float64
+	RelativeFail float64
+	PointsWarn   float64
+	PointsFail   float64
+}
+
+var DefaultCompareThresholds = CompareThresholds{0.05, 0.10, 1, 5}
+
+type MetricDiff struct {
+	Path            string
+	Kind            MetricKind
+	Baseline        float64
+	Current         float64
+	Delta           float64 // Current - Baseline
+	Change          float64 // Delta / Baseline, or percentage points for fractions and percents
+	Status          CompareStatus
+	BaselineMissing bool // from the baseline report, so Baseline is 0
+	CurrentMissing  bool // from the current report, so Current is 0
+}
+
+type Comparison struct {
+	Status CompareStatus // the worst of Diffs
+	Diffs  []*MetricDiff
+}
+
+// CompareReports compares every metric of current with baseline by
+// DefaultCompareThresholds.
+func CompareReports(baseline, current *Report) *Comparison {
+	return CompareReportsWith(baseline, current, DefaultCompareThresholds)
+}
+
+func CompareReportsWith(baseline, current *Report, t CompareThresholds) *Comparison {
+	b := baseline.Metrics()
+	c := current.Metrics()
+	r := &Comparison{ComparePass, make([]*MetricDiff, 0, len(b))}
+
+	// both are sorted by path
+	i, j := 0, 0
+	for i < len(b) || j < len(c) {
+		var d *MetricDiff
+		if j >= len(c) || (i < len(b) && b[i].Path < c[j].Path) {
+			d = compareMissing(b[i], &Metric{b[i].Path, b[i].Kind, 0, b[i].worse}, t)
+			d.CurrentMissing = true
+			i++
+		} else if i >= len(b) || c[j].Path < b[i].Path {
+			d = compareMissing(&Metric{c[j].Path, c[j].Kind, 0, c[j].worse}, c[j], t)
+			d.BaselineMissing = true
+			j++
+		} else {
+			d = compareMetric(b[i], c[j], t)
+			i++
+			j++
+		}
+
+		if d.Status > r.Status {
+			r.Status = d.Status
+		}
+
+		r.Diffs = append(r.Diffs, d)
+	}
+
+	return r
+}
+
+// compareMissing compares a metric missing from one of the reports, where
+// it is zero. Counts and fractions that are worse higher, like those of an
+// error class, are compared as such, and others only warn.
+func compareMissing(b, c *Metric, t CompareThresholds) *MetricDiff {
+	if b.worse == higherIsWorse && b.Kind != MetricDuration {
+		return compareMetric(b, c, t)
+	}
+
+	return &MetricDiff{Path: b.Path, Kind: b.Kind, Baseline: b.Value, Current: c.Value, Status: CompareWarn}
+}
+
+func compareMetric(b, c *Metric, t CompareThresholds) *MetricDiff {
+	d := &MetricDiff{Path: b.Path, Kind: b.Kind, Baseline: b.Value, Current: c.Value, Delta: c.Value - b.Value}
+	warn, fail := t.RelativeWarn, t.RelativeFail
+	if b.Kind == MetricFraction {
+		d.Change = d.Delta * 100
+		warn, fail = t.PointsWarn, t.PointsFail
+	} else if b.Kind == MetricPercent {
+		d.Change = d.Delta
+		warn, fail = t.PointsWarn, t.PointsFail
+	} else if b.Value != 0 {
+		d.Change = d.Delta / math.Abs(b.Value)
+	} else if d.Delta != 0 {
+		d.Change = math.Copysign(math.Inf(1), d.Delta)
+	}
+
+	worse := 0.0
+	switch b.worse {
+	case higherIsWorse:
+		worse = d.Change
+	case lowerIsWorse:
+		worse = -d.Change
+	case changeIsWorse:
+		worse = math.Abs(d.Change)
+	}
+
+	if worse > fail {
+		d.Status = CompareFail
+	} else if worse > warn {
+		d.Status = CompareWarn
+	}
+
+	return d
+}
+
+// Regressed says if any metric failed.
+func (c *Comparison) Regressed() bool {
+	return c.Status == CompareFail
+}
+
+func (c *Comparison) String() string {
+	return c.string(ComparePass)
+}
+
+// Filter renders only the metrics at least as bad as status.
+func (c *Comparison) Filter(status CompareStatus) string {
+	return c.string(status)
+}
+
+func (c *Comparison) string(status CompareStatus) string {
+	r := make([]string, 0, len(c.Diffs)+1)
+	r = append(r, "Compare: "+c.Status.String())
+	for _, d := range c.Diffs {
+		if d.Status >= status {
+			r = append(r, "    "+d.String())
+		}
+	}
+
+	return strings.Join(r, "\n") + "\n"
+}
+
+func (d *MetricDiff) String() string {
+	if d.BaselineMissing || d.CurrentMissing {
+		return fmt.Sprintf("%4s  %-50s %15s -> %15s", d.Status, d.Path, d.Kind.formatMissing(d.Baseline, d.BaselineMissing), d.Kind.formatMissing(d.Current, d.CurrentMissing))
+	}
+
+	change := ""
+	if d.Kind == MetricFraction || d.Kind == MetricPercent {
+		change = fmt.Sprintf("%+.2f pt", d.Change)
+	} else {
+		change = fmt.Sprintf("%+.2f%%", d.Change*100)
+	}
+
+	return fmt.Sprintf("%4s  %-50s %15s -> %15s  (%s)", d.Status, d.Path, d.Kind.format(d.Baseline), d.Kind.format(d.Current), change)
+}
+
+func (k MetricKind) formatMissing(v float64, missing bool) string {
+	if missing {
+		return "-"
+	}
+
+	return k.format(v)
+}
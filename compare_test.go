@@ -0,0 +1,95 @@
+package antpost
+
+import "testing"
+
+import (
+	"strings"
+	"time"
+)
+
+func testReport(latency time.Duration, rps float64, errors float64, trues int) *Report {
+	c := NewContext()
+	c.SetPercentiles(0.75)
+	for i := 0; i < 10; i++ {
+		c.stat.Bool("b", i < trues)
+		c.stat.Nominal("n", "x")
+		c.stat.Ratio("r", 100)
+	}
+
+	r := c.Report()
+	r.Summary = &SummaryReport{N: 100, RPS: rps, ErrorRate: errors}
+	r.Time = &DurationReport{N: 100, Avg: latency, P95: latency * 2, Max: latency * 4, Percentiles: []*DurationPercentile{{0.75, latency * 3}}}
+	return r
+}
+
+func TestQuantileName(t *testing.T) {
+	for q, name := range map[float64]string{0.05: "P05", 0.5: "P50", 0.75: "P75", 0.999: "P999", 0.9999: "P9999", 0.001: "P001"} {
+		if n := quantileName(q); n != name {
+			t.Errorf("quantileName(%v) => %v != %v", q, n, name)
+		}
+	}
+}
+
+func TestCompareReports(t *testing.T) {
+	base := testReport(100*time.Millisecond, 1000, 0.01, 5)
+	if c := CompareReports(base, base); c.Status != ComparePass {
+		t.Errorf("CompareReports(same) => %v", c)
+	}
+
+	c := CompareReports(base, testReport(107*time.Millisecond, 1100, 0.01, 5))
+	if c.Status != CompareWarn || c.Regressed() {
+		t.Errorf("CompareReports(slower) => %v", c)
+	}
+
+	status := make(map[string]CompareStatus)
+	for _, d := range c.Diffs {
+		status[d.Path] = d.Status
+	}
+
+	if status["Time.Avg"] != CompareWarn || status["Time.P95"] != CompareWarn || status["Summary.RPS"] != ComparePass || status["Time.P75"] != CompareWarn || status["Time.Max"] != ComparePass {
+		t.Errorf("CompareReports(slower) diffs => %v", c)
+	}
+
+	c = CompareReports(base, testReport(100*time.Millisecond, 800, 0.08, 9))
+	status = make(map[string]CompareStatus)
+	for _, d := range c.Diffs {
+		status[d.Path] = d.Status
+	}
+
+	if !c.Regressed() || status["Summary.RPS"] != CompareFail || status["Summary.ErrorRate"] != CompareFail || status["Bools.b.TruePercent"] != CompareFail {
+		t.Errorf("CompareReports(worse) => %v", c)
+	}
+
+	if status["Ratios.r.Mean"] != ComparePass || status["Nominals.n.x.Percent"] != ComparePass || status["Summary.N"] != ComparePass {
+		t.Errorf("CompareReports(worse) unchanged metrics => %v", c)
+	}
+}
+
+func TestCompareReportsMissing(t *testing.T) {
+	base := testReport(100*time.Millisecond, 1000, 0.01, 5)
+	current := testReport(100*time.Millisecond, 1000, 0.01, 5)
+	current.Errors = &ErrorReport{5, []*ErrorClassReport{{ErrorTimeout, 5, 100, nil}}}
+	c := CompareReports(base, current)
+	status := make(map[string]CompareStatus)
+	for _, d := range c.Diffs {
+		status[d.Path] = d.Status
+	}
+
+	if !c.Regressed() || status["Errors.timeout.N"] != CompareFail || status["Errors.timeout.Percent"] != CompareWarn {
+		t.Errorf("CompareReports(new error class) => %v", c)
+	}
+
+	if c := CompareReports(current, base); c.Status != CompareWarn {
+		t.Errorf("CompareReports(error class gone) => %v", c)
+	}
+
+	d := &MetricDiff{Path: "Summary.CheckFail", Kind: MetricCount, Current: 3, Status: CompareFail, BaselineMissing: true}
+	if s := d.String(); !strings.Contains(s, "- ->") {
+		t.Errorf("MetricDiff.String() of a missing baseline: %q", s)
+	}
+
+	d = &MetricDiff{Path: "Summary.CheckFail", Kind: MetricCount, Status: CompareWarn, CurrentMissing: true}
+	if s := d.String(); !strings.Contains(s, " 0 -> ") || !strings.HasSuffix(s, " -") {
+		t.Errorf("MetricDiff.String() of a zero baseline: %q", s)
+	}
+}
@@ -1,9 +1,11 @@
 package antpost
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"sort"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,11 +39,21 @@ type Stat interface {
 }
 
 type Context struct {
-	cur     *droneContext
-	history []*droneContext
-	count   int
-	timer   *time.Timer
-	stat    *stat
+	cur      *droneContext
+	history  []*droneContext
+	count    int
+	timer    *time.Timer
+	stat     *stat
+	arrivals *arrivalStat
+	stage    string
+	ctx      context.Context
+	canceled bool
+	budget   *int64
+	worker   int
+	watchers []Watcher
+	deferred []func()
+
+	percentiles []float64
 }
 
 func NewContext() *Context {
@@ -49,6 +61,7 @@ func NewContext() *Context {
 	c.history = make([]*droneContext, 0)
 	c.count = -1
 	c.stat = newStat()
+	c.ctx = context.Background()
 	return c
 }
 
@@ -61,48 +74,300 @@ func (c *Context) SetTime(d time.Duration) {
 	c.timer = time.NewTimer(d)
 }
 
+// SetContext makes Start() stop once ctx is done, and reports iterations to
+// the watchers ctx carries. Drones get ctx from Context() so that they can
+// abort requests in flight.
+func (c *Context) SetContext(ctx context.Context) {
+	c.ctx = ctx
+	c.watchers = watchersFrom(ctx)
+}
+
+func (c *Context) Context() context.Context {
+	return c.ctx
+}
+
+// Defer has f called once the worker running with c is done, for drones to
+// release what they keep for the worker.
+func (c *Context) Defer(f func()) {
+	c.deferred = append(c.deferred, f)
+}
+
+// release calls the functions given to Defer, the last first.
+func (c *Context) release() {
+	for i := len(c.deferred) - 1; i >= 0; i-- {
+		c.deferred[i]()
+	}
+
+	c.deferred = nil
+}
+
+// SetPrecision sets the significant decimal digits kept for Stat.Duration
+// and Stat.Ratio values recorded from now on, DefaultPrecision by default.
+func (c *Context) SetPrecision(digits int) {
+	c.stat.setPrecision(digits)
+}
+
+// SetPercentiles adds quantiles, each in [0, 1], to every duration and
+// ratio in Report().
+func (c *Context) SetPercentiles(quantiles ...float64) {
+	c.percentiles = quantiles
+}
+
+func (c *Context) analyze(times []time.Duration) *DurationReport {
+	return AnalyzeDurationPercentiles(times, c.percentiles...)
+}
+
 func (c *Context) Combine(contexts ...*Context) {
 	for _, v := range contexts {
 		c.history = append(c.history, v.history...)
 		c.stat.combine(v.stat)
+		c.canceled = c.canceled || v.canceled
+		if v.arrivals != nil {
+			if c.arrivals == nil {
+				c.arrivals = new(arrivalStat)
+			}
+
+			c.arrivals.combine(v.arrivals)
+		}
 	}
 }
 
 func (c *Context) Report() *Report {
-	n := len(c.history)
-	if n <= 0 {
-		return nil
+	r := new(Report)
+	c.phases(r, c.history)
+	r.Stat = c.stat.report(c.percentiles)
+	r.Canceled = c.canceled
+	if err := abortFrom(c.ctx); err != nil && c.canceled {
+		r.Abort = &AbortReport{err.Reason, err.At, err.Elapsed}
+	}
+
+	if c.arrivals != nil {
+		r.Arrivals = c.arrivals.report()
 	}
 
-	start := c.history[0].start
-	end := c.history[0].end
+	r.Stages = c.stageReports()
+	r.Workers = c.workerReports()
+	r.Labels = c.labelReports()
+	r.Errors = c.errorReport()
+	r.Timeline = c.Timeline(time.Second)
+	return r
+}
+
+// phases sets the summary and the times of r from history.
+func (c *Context) phases(r *Report, history []*droneContext) {
+	n := len(history)
+	var start, end time.Time
+	if n > 0 {
+		start = history[0].start
+		end = history[0].end
+	}
 
+	summary := new(SummaryReport)
 	d := make([]time.Duration, 0, n)
 	okd := make([]time.Duration, 0, n)
-	for _, h := range c.history {
+	connect := make([]time.Duration, 0, n)
+	handshake := make([]time.Duration, 0)
+	response := make([]time.Duration, 0, n)
+	for _, h := range history {
 		if h.start.Before(start) {
 			start = h.start
 		}
 
-		if end.After(h.end) {
+		if h.end.After(end) {
 			end = h.end
 		}
 
+		switch h.result {
+		case ResultOK:
+			summary.OK++
+		case ResultConnectFail:
+			summary.ConnectFail++
+		case ResultResponseBroken:
+			summary.ResponseBroken++
+		case ResultCheckFail:
+			summary.CheckFail++
+		}
+
+		summary.BytesSent += h.sent
+		summary.BytesReceived += h.received
+
 		d = append(d, h.end.Sub(h.start))
 		if h.step == StepResponsed && h.result == ResultOK {
 			okd = append(okd, h.end.Sub(h.start))
 		}
+
+		// a drone that never connected spent all its time connecting
+		if !h.connected.IsZero() {
+			connect = append(connect, h.connected.Sub(h.start))
+		} else {
+			connect = append(connect, h.end.Sub(h.start))
+		}
+
+		if !h.connected.IsZero() && !h.handshaked.IsZero() {
+			handshake = append(handshake, h.handshaked.Sub(h.connected))
+		}
+
+		if !h.handshaked.IsZero() && !h.responsed.IsZero() {
+			response = append(response, h.responsed.Sub(h.handshaked))
+		} else if !h.connected.IsZero() && !h.responsed.IsZero() {
+			response = append(response, h.responsed.Sub(h.connected))
+		}
 	}
 
-	r := new(Report)
-	r.Time = AnalyzeDurationReport(d)
-	r.OKTime = AnalyzeDurationReport(okd)
-	r.Stat = c.stat.Report()
+	summary.N = n
+	summary.Elapsed = end.Sub(start)
+	if summary.Elapsed > 0 {
+		summary.RPS = float64(n) / summary.Elapsed.Seconds()
+	}
+
+	if n > 0 {
+		summary.ErrorRate = float64(n-summary.OK) / float64(n)
+	}
+
+	r.Summary = summary
+	r.Time = c.analyze(d)
+	r.OKTime = c.analyze(okd)
+	r.ConnectTime = c.analyze(connect)
+	if len(handshake) > 0 {
+		r.HandshakeTime = c.analyze(handshake)
+	}
+
+	r.ResponseTime = c.analyze(response)
+}
+
+func (c *Context) stageReports() []*StageReport {
+	stages := make([]*StageReport, 0)
+	index := make(map[string]int)
+	d := make([][]time.Duration, 0)
+	okd := make([][]time.Duration, 0)
+	for _, h := range c.history {
+		if h.stage == "" {
+			continue
+		}
+
+		i, ok := index[h.stage]
+		if !ok {
+			i = len(stages)
+			index[h.stage] = i
+			stages = append(stages, &StageReport{Name: h.stage, Start: h.start})
+			d = append(d, make([]time.Duration, 0))
+			okd = append(okd, make([]time.Duration, 0))
+		} else if h.start.Before(stages[i].Start) {
+			stages[i].Start = h.start
+		}
+
+		d[i] = append(d[i], h.end.Sub(h.start))
+		if h.step == StepResponsed && h.result == ResultOK {
+			okd[i] = append(okd[i], h.end.Sub(h.start))
+		}
+	}
+
+	if len(stages) == 0 {
+		return nil
+	}
+
+	for i, s := range stages {
+		s.Time = c.analyze(d[i])
+		s.OKTime = c.analyze(okd[i])
+	}
+
+	sort.Stable(stageReports(stages))
+	return stages
+}
+
+func (c *Context) workerReports() []*WorkerReport {
+	workers := make(map[int]*WorkerReport)
+	d := make(map[int][]time.Duration)
+	for _, h := range c.history {
+		if h.worker <= 0 {
+			continue
+		}
+
+		w, ok := workers[h.worker]
+		if !ok {
+			w = &WorkerReport{Worker: h.worker}
+			workers[h.worker] = w
+		}
+
+		w.N++
+		if h.result == ResultOK {
+			w.OK++
+		}
+
+		d[h.worker] = append(d[h.worker], h.end.Sub(h.start))
+	}
+
+	if len(workers) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(workers))
+	for id, _ := range workers {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+	r := make([]*WorkerReport, 0, len(ids))
+	for _, id := range ids {
+		w := workers[id]
+		w.Time = c.analyze(d[id])
+		r = append(r, w)
+	}
 
 	return r
 }
 
+func (c *Context) labelReports() []*LabelReport {
+	history := make(map[string][]*droneContext)
+	for _, h := range c.history {
+		if h.label != "" {
+			history[h.label] = append(history[h.label], h)
+		}
+	}
+
+	if len(history) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(history))
+	for name, _ := range history {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	labels := make([]*LabelReport, 0, len(names))
+	for _, name := range names {
+		r := new(Report)
+		c.phases(r, history[name])
+		labels = append(labels, &LabelReport{name, r.Summary, r.Time, r.OKTime, r.ConnectTime, r.HandshakeTime, r.ResponseTime})
+	}
+
+	return labels
+}
+
+type stageReports []*StageReport
+
+func (s stageReports) Len() int           { return len(s) }
+func (s stageReports) Less(i, j int) bool { return s[i].Start.Before(s[j].Start) }
+func (s stageReports) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 func (c *Context) Start() bool {
+	return c.StartAt(time.Time{})
+}
+
+// StartAt is Start with an intended start time, so that time spent waiting
+// for the drone to be scheduled is counted in the report. A zero time means
+// now.
+func (c *Context) StartAt(intended time.Time) bool {
+	if c.count != 0 {
+		select {
+		case <-c.ctx.Done():
+			c.count = 0
+			c.canceled = true
+		default:
+		}
+	}
+
 	if c.count != 0 {
 		if c.timer != nil {
 			select {
@@ -116,15 +381,76 @@ func (c *Context) Start() bool {
 
 	if c.count == 0 {
 		return false
+	} else if c.budget != nil && atomic.AddInt64(c.budget, -1) < 0 {
+		c.count = 0
+		return false
 	} else if c.count > 0 {
 		c.count--
 	}
 
 	c.cur = new(droneContext)
-	c.cur.start = time.Now()
+	c.cur.stage = c.stage
+	c.cur.worker = c.worker
+	if intended.IsZero() {
+		c.cur.start = time.Now()
+	} else {
+		c.cur.start = intended
+	}
+
 	return true
 }
 
+// Wait waits until t, unless the run is done or out of time first, and
+// reports whether t came. If not, the current iteration is dropped from the
+// report, and the run stops. The wait is not counted in the time of the
+// current iteration.
+func (c *Context) Wait(t time.Time) bool {
+	if c.cur == nil {
+		panic(fmt.Errorf("Wait() without Start()"))
+	}
+
+	came := true
+	if wait := t.Sub(time.Now()); wait > 0 {
+		var deadline <-chan time.Time
+		if c.timer != nil {
+			deadline = c.timer.C
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-c.ctx.Done():
+			came = false
+		case <-deadline:
+			c.count = 0
+			c.timer = nil
+			came = false
+		}
+
+		timer.Stop()
+	}
+
+	if now := time.Now(); c.cur.start.Before(now) {
+		c.cur.start = now
+	}
+
+	if !came {
+		c.Drop()
+	}
+
+	return came
+}
+
+// Drop drops the current iteration from the report, for drones that find
+// nothing left to do.
+func (c *Context) Drop() {
+	if c.cur == nil {
+		panic(fmt.Errorf("Drop() without Start()"))
+	}
+
+	c.cur.dropped = true
+}
+
 func (c *Context) Step(step DroneStep) {
 	if c.cur == nil {
 		panic(fmt.Errorf("Step() without Start()"))
@@ -139,10 +465,21 @@ func (c *Context) Step(step DroneStep) {
 		c.cur.step = step
 		c.cur.connected = time.Now()
 	case StepConnected:
-		if step != StepResponsed {
+		if step == StepHandshaked {
+			c.cur.step = step
+			c.cur.handshaked = time.Now()
+			return
+		} else if step != StepResponsed {
 			panic(fmt.Errorf("Error step from StepConnected"))
 		}
 
+		c.cur.step = step
+		c.cur.responsed = time.Now()
+	case StepHandshaked:
+		if step != StepResponsed {
+			panic(fmt.Errorf("Error step from StepHandshaked"))
+		}
+
 		c.cur.step = step
 		c.cur.responsed = time.Now()
 	}
@@ -153,11 +490,54 @@ func (c *Context) End(result DroneResult) {
 		panic(fmt.Errorf("End() without Start()"))
 	}
 
+	if c.cur.dropped {
+		c.cur = nil
+		return
+	}
+
 	c.cur.End(result)
 	c.history = append(c.history, c.cur)
+	if len(c.watchers) > 0 {
+		it := c.cur.iteration()
+		for _, w := range c.watchers {
+			w.Watch(it)
+		}
+	}
+
 	c.cur = nil
 }
 
+// Label names the current iteration, e.g. by its endpoint or scenario step,
+// for Report to break iterations down by label.
+func (c *Context) Label(name string) {
+	if c.cur == nil {
+		panic(fmt.Errorf("Label() without Start()"))
+	}
+
+	c.cur.label = name
+}
+
+// Error tells why the current iteration failed, for Report to break errors
+// down by ClassifyError.
+func (c *Context) Error(err error) {
+	if c.cur == nil {
+		panic(fmt.Errorf("Error() without Start()"))
+	}
+
+	c.cur.err = err
+}
+
+// Bytes adds to the bytes the current iteration sent and received, for
+// Report to sum up.
+func (c *Context) Bytes(sent, received int64) {
+	if c.cur == nil {
+		panic(fmt.Errorf("Bytes() without Start()"))
+	}
+
+	c.cur.sent += sent
+	c.cur.received += received
+}
+
 func (c *Context) Bool(name string, value bool) {
 	c.stat.Bool(name, value)
 }
@@ -175,18 +555,28 @@ func (c *Context) Stat() Stat {
 }
 
 type droneContext struct {
-	step      DroneStep
-	start     time.Time
-	connected time.Time
-	responsed time.Time
-	result    DroneResult
-	end       time.Time
+	worker     int
+	stage      string
+	label      string
+	step       DroneStep
+	start      time.Time
+	connected  time.Time
+	handshaked time.Time
+	responsed  time.Time
+	result     DroneResult
+	err        error
+	sent       int64
+	received   int64
+	end        time.Time
+	dropped    bool // by Drop, so not reported
 }
 
 func (c *droneContext) End(result DroneResult) {
 	c.result = result
 	if !c.responsed.IsZero() {
 		c.end = c.responsed
+	} else if !c.handshaked.IsZero() {
+		c.end = c.handshaked
 	} else if !c.connected.IsZero() {
 		c.end = c.connected
 	} else {
@@ -194,20 +584,55 @@ func (c *droneContext) End(result DroneResult) {
 	}
 }
 
+type arrivalStat struct {
+	rate      float64
+	scheduled int
+	issued    int
+	delayed   int
+	maxLag    time.Duration
+}
+
+func (a *arrivalStat) combine(v *arrivalStat) {
+	a.rate += v.rate
+	a.scheduled += v.scheduled
+	a.issued += v.issued
+	a.delayed += v.delayed
+	if v.maxLag > a.maxLag {
+		a.maxLag = v.maxLag
+	}
+}
+
+func (a *arrivalStat) report() *ArrivalReport {
+	r := new(ArrivalReport)
+	r.Rate = a.rate
+	r.Scheduled = a.scheduled
+	r.Issued = a.issued
+	r.Delayed = a.delayed
+	r.Dropped = a.scheduled - a.issued
+	r.MaxLag = a.maxLag
+	return r
+}
+
 type stat struct {
 	bools     map[string][]bool
-	durations map[string][]time.Duration
+	durations map[string]*histogram
 	subs      map[string]*stat
 	nominals  map[string]*nominalStat
 	ordinals  map[string]*ordinalStat
 	intervals map[string]*intervalStat
 	ratios    map[string]*ratioStat
+	digits    int
 }
 
 func newStat() *stat {
+	return newStatPrecision(DefaultPrecision)
+}
+
+func newStatPrecision(digits int) *stat {
 	s := new(stat)
+	s.digits = digits
 	s.bools = make(map[string][]bool)
-	s.durations = make(map[string][]time.Duration)
+	s.durations = make(map[string]*histogram)
 	s.subs = make(map[string]*stat)
 	s.nominals = make(map[string]*nominalStat)
 	s.ordinals = make(map[string]*ordinalStat)
@@ -228,16 +653,24 @@ func (s *stat) Bool(name string, value bool) {
 func (s *stat) Duration(name string, duration time.Duration) {
 	v, ok := s.durations[name]
 	if !ok {
-		v = make([]time.Duration, 0)
+		v = newHistogram(s.digits)
+		s.durations[name] = v
 	}
 
-	s.durations[name] = append(v, duration)
+	v.record(float64(duration))
+}
+
+func (s *stat) setPrecision(digits int) {
+	s.digits = digits
+	for _, sub := range s.subs {
+		sub.setPrecision(digits)
+	}
 }
 
 func (s *stat) Sub(name string) Stat {
 	v, ok := s.subs[name]
 	if !ok {
-		v = newStat()
+		v = newStatPrecision(s.digits)
 		s.subs[name] = v
 	}
 
@@ -297,7 +730,7 @@ func (s *stat) IntervalInit(name string, interval float64) {
 func (s *stat) Ratio(name string, value float64) {
 	r, ok := s.ratios[name]
 	if !ok {
-		r = newRatioStat()
+		r = newRatioStat(s.digits)
 		s.ratios[name] = r
 	}
 
@@ -305,6 +738,10 @@ func (s *stat) Ratio(name string, value float64) {
 }
 
 func (s *stat) Report() *StatReport {
+	return s.report(nil)
+}
+
+func (s *stat) report(percentiles []float64) *StatReport {
 	r := new(StatReport)
 	r.Bools = make(map[string]*BoolReport)
 	r.Durations = make(map[string]*DurationReport)
@@ -319,11 +756,11 @@ func (s *stat) Report() *StatReport {
 	}
 
 	for n, d := range s.durations {
-		r.Durations[n] = AnalyzeDurationReport(d)
+		r.Durations[n] = d.durationReport(percentiles)
 	}
 
 	for n, s := range s.subs {
-		r.Subs[n] = s.Report()
+		r.Subs[n] = s.report(percentiles)
 	}
 
 	for n, v := range s.nominals {
@@ -339,7 +776,7 @@ func (s *stat) Report() *StatReport {
 	}
 
 	for n, v := range s.ratios {
-		r.Ratios[n] = v.report()
+		r.Ratios[n] = v.report(percentiles)
 	}
 
 	return r
@@ -360,12 +797,10 @@ func (s *stat) combine(v *stat) {
 	for n, d := range v.durations {
 		a, ok := s.durations[n]
 		if ok {
-			a = append(a, d...)
+			a.combine(d)
 		} else {
-			a = d
+			s.durations[n] = d
 		}
-
-		s.durations[n] = a
 	}
 
 	for n, sub := range v.subs {
@@ -703,44 +1138,59 @@ func (i *intervalStat) report() *IntervalReport {
 }
 
 type ratioStat struct {
-	values []float64
+	values *histogram
 }
 
-func newRatioStat() *ratioStat {
+func newRatioStat(digits int) *ratioStat {
 	r := new(ratioStat)
-	r.values = make([]float64, 0)
+	r.values = newHistogram(digits)
 	return r
 }
 
 func (r *ratioStat) Value(value float64) {
-	r.values = append(r.values, value)
+	r.values.record(value)
 }
 
 func (r *ratioStat) combine(v *ratioStat) {
-	r.values = append(r.values, v.values...)
+	r.values.combine(v.values)
 }
 
-func (r *ratioStat) report() *RatioReport {
-	if len(r.values) <= 0 {
-		return &RatioReport{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+func (r *ratioStat) report(percentiles []float64) *RatioReport {
+	h := r.values
+	if h.n <= 0 {
+		return &RatioReport{N: 0}
 	}
 
 	ratio := new(RatioReport)
 
-	ratio.N = len(r.values)
-
-	ratio.Mean = calcMean(r.values)
-	ratio.GeometricMean = calcGeometricMean(r.values)
-	ratio.QuadraticMean = calcQuadraticMean(r.values)
-	ratio.HarmonicMean = calcHarmonicMean(r.values)
-
-	ratio.StandardDeviation = calcStandardDeviation(r.values)
+	ratio.N = int(h.n)
+
+	ratio.Mean = h.mean()
+	ratio.GeometricMean = h.geometricMean()
+	ratio.QuadraticMean = h.quadraticMean()
+	ratio.HarmonicMean = h.harmonicMean()
+
+	ratio.StandardDeviation = h.standardDeviation()
+
+	p := h.percentiles(append([]float64{0.05, 0.25, 0.50, 0.75, 0.95, 0.90, 0.99, 0.999, 0.9999}, percentiles...)...)
+	ratio.P05 = p[0]
+	ratio.P25 = p[1]
+	ratio.P50 = p[2]
+	ratio.P75 = p[3]
+	ratio.P95 = p[4]
+
+	ratio.Min = h.min
+	ratio.P90 = p[5]
+	ratio.P99 = p[6]
+	ratio.P999 = p[7]
+	ratio.P9999 = p[8]
+	ratio.Max = h.max
+	if len(percentiles) > 0 {
+		ratio.Percentiles = make([]*RatioPercentile, 0, len(percentiles))
+		for i, q := range percentiles {
+			ratio.Percentiles = append(ratio.Percentiles, &RatioPercentile{q, p[9+i]})
+		}
+	}
 
-	sort.Float64s(r.values)
-	ratio.P05 = r.values[ratio.N*5/100]
-	ratio.P25 = r.values[ratio.N*25/100]
-	ratio.P50 = r.values[ratio.N*50/100]
-	ratio.P75 = r.values[ratio.N*75/100]
-	ratio.P95 = r.values[ratio.N*95/100]
 	return ratio
 }
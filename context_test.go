@@ -4,6 +4,8 @@ import "testing"
 
 import (
 	"fmt"
+	"math"
+	"time"
 )
 
 func TestStat(t *testing.T) {
@@ -62,3 +64,97 @@ func TestStat(t *testing.T) {
 		t.Errorf("Ratios failed")
 	}
 }
+
+func TestReportSummary(t *testing.T) {
+	c := NewContext()
+	begin := time.Now()
+	ends := []time.Duration{300, 100, 200, 400}
+	results := []DroneResult{ResultOK, ResultConnectFail, ResultOK, ResultResponseBroken}
+	for i, end := range ends {
+		start := begin.Add(time.Duration(i) * 100 * time.Millisecond)
+		c.history = append(c.history, &droneContext{start: start, end: start.Add(end * time.Millisecond), result: results[i]})
+	}
+
+	s := c.Report().Summary
+	if s.N != 4 || s.OK != 2 || s.ConnectFail != 1 || s.ResponseBroken != 1 {
+		t.Errorf("Report().Summary counts: %v", s)
+	}
+
+	if s.Elapsed != 700*time.Millisecond || s.RPS != 4/0.7 || s.ErrorRate != 0.5 {
+		t.Errorf("Report().Summary elapsed: %v", s)
+	}
+}
+
+func TestReportPhases(t *testing.T) {
+	c := NewContext()
+	begin := time.Now()
+	ms := time.Millisecond
+	c.history = append(c.history, &droneContext{step: StepResponsed, start: begin, connected: begin.Add(10 * ms), responsed: begin.Add(50 * ms), end: begin.Add(50 * ms)})
+	c.history = append(c.history, &droneContext{step: StepResponsed, start: begin, connected: begin.Add(30 * ms), responsed: begin.Add(40 * ms), end: begin.Add(40 * ms)})
+	c.history = append(c.history, &droneContext{step: StepInit, start: begin, end: begin.Add(80 * ms), result: ResultConnectFail})
+
+	r := c.Report()
+	if r.ConnectTime.N != 3 || r.ConnectTime.Avg != 40*ms {
+		t.Errorf("Report().ConnectTime: %v", r.ConnectTime)
+	}
+
+	if r.ResponseTime.N != 2 || r.ResponseTime.Avg != 25*ms {
+		t.Errorf("Report().ResponseTime: %v", r.ResponseTime)
+	}
+}
+
+func TestReportLabels(t *testing.T) {
+	c := NewContext()
+	for i, label := range []string{"b", "a", "b", ""} {
+		c.Start()
+		c.Label(label)
+		c.Step(StepConnected)
+		if i == 0 {
+			c.End(ResultConnectFail)
+			continue
+		}
+
+		c.Step(StepResponsed)
+		c.End(ResultOK)
+	}
+
+	r := c.Report()
+	if len(r.Labels) != 2 || r.Labels[0].Name != "a" || r.Labels[1].Name != "b" {
+		t.Errorf("Report().Labels: %v", r.Labels)
+		return
+	}
+
+	b := r.Labels[1]
+	if b.Summary.N != 2 || b.Summary.OK != 1 || b.Summary.ConnectFail != 1 || b.Time.N != 2 || b.OKTime.N != 1 {
+		t.Errorf("Report().Labels[b]: %v, %v", b.Summary, b.OKTime)
+	}
+
+	if m, ok := r.Metric("Labels.b.Summary.ErrorRate"); !ok || m.Value != 0.5 {
+		t.Errorf("Report().Metric(Labels.b.Summary.ErrorRate): %v", m)
+	}
+}
+
+func TestReportPercentiles(t *testing.T) {
+	c := NewContext()
+	c.SetPercentiles(0.75)
+	begin := time.Now()
+	for i := 1; i <= 1000; i++ {
+		c.history = append(c.history, &droneContext{start: begin, end: begin.Add(time.Duration(i) * time.Millisecond)})
+		c.stat.Ratio("r", float64(i))
+	}
+
+	r := c.Report()
+	d := r.Time
+	if d.Min != time.Millisecond || d.Max != time.Second || d.P99 != 990010*time.Microsecond || d.P999 != 999001*time.Microsecond {
+		t.Errorf("Report().Time percentiles: %v", d)
+	}
+
+	if len(d.Percentiles) != 1 || d.Percentiles[0].Value != 750250*time.Microsecond {
+		t.Errorf("Report().Time custom percentiles: %v", d)
+	}
+
+	ratio := r.Stat.Ratios["r"]
+	if math.Abs(ratio.P90-900.1) > 1 || len(ratio.Percentiles) != 1 || math.Abs(ratio.Percentiles[0].Value-750.25) > 1 {
+		t.Errorf("Report() ratio percentiles: %v", ratio.string())
+	}
+}
@@ -4,21 +4,23 @@ import (
 	"github.com/benbearchen/antpost"
 
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"math"
 	"net"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type AsyncHttpResposne struct {
 	StatusCode int
 	Content    []byte
-	Err        error
+	Err        error // malformed response, after which the connection is dropped
+	Proto      string
+	Header     http.Header // trailers of chunked responses included
 }
 
 type AsyncHttpReq struct {
@@ -32,6 +34,11 @@ type AsyncHttpReq struct {
 type AsyncHttpSession struct {
 	Host     string
 	Operator AsyncOperator
+
+	// TLS, if not nil, makes the session HTTPS, on port 443 by default.
+	// Its ServerName, for SNI and verifying, defaults to the host name, and
+	// its NextProtos, for ALPN, to "http/1.1", the only protocol spoken.
+	TLS *tls.Config
 }
 
 type AsyncOperator interface {
@@ -48,13 +55,29 @@ type asyncHttpDrone struct {
 	http *AsyncHttpSession
 }
 
+// Run steps to StepHandshaked after the TLS handshake of HTTPS sessions.
 func (h *asyncHttpDrone) Run(context *antpost.Context) antpost.DroneResult {
-	async, err := newAsyncHttp(h.http.Host)
+	ctx := context.Context()
+	conn, host, err := dialAsyncHttp(ctx, h.http.Host, h.http.TLS != nil)
 	context.Step(antpost.StepConnected)
 	if err != nil {
+		context.Error(err)
 		return antpost.ResultConnectFail
 	}
 
+	if h.http.TLS != nil {
+		conn, err = handshakeAsyncHttp(ctx, conn, host, h.http.TLS)
+		if err != nil {
+			context.Error(err)
+			return antpost.ResultConnectFail
+		}
+
+		context.Step(antpost.StepHandshaked)
+	}
+
+	async := newAsyncHttpConn(ctx, conn)
+
+	var broken, status error
 	p, q := false, false
 	for !p && !q {
 		select {
@@ -62,12 +85,14 @@ func (h *asyncHttpDrone) Run(context *antpost.Context) antpost.DroneResult {
 			if !ok {
 				p = true
 			} else {
+				recordRequest(context, len(req.Data))
 				async.Do(req.Url, req.Method, req.Header, req.Data, req.KeepAlive)
 			}
 		case response, ok := <-async.Response():
 			if !ok {
 				q = true
 			} else {
+				broken, status = h.record(context, response, broken, status)
 				h.http.Operator.Response(context, response)
 			}
 		}
@@ -82,6 +107,7 @@ func (h *asyncHttpDrone) Run(context *antpost.Context) antpost.DroneResult {
 				if !ok {
 					q = true
 				} else {
+					broken, status = h.record(context, response, broken, status)
 					h.http.Operator.Response(context, response)
 				}
 			}
@@ -89,13 +115,37 @@ func (h *asyncHttpDrone) Run(context *antpost.Context) antpost.DroneResult {
 	}
 
 	context.Step(antpost.StepResponsed)
-	if err != nil {
+	context.Bytes(atomic.LoadInt64(&async.sent), atomic.LoadInt64(&async.received))
+	if err := context.Context().Err(); err != nil {
+		context.Error(err)
 		return antpost.ResultResponseBroken
+	} else if broken != nil {
+		context.Error(broken)
+		return antpost.ResultResponseBroken
+	} else if status != nil {
+		context.Error(status)
+		return antpost.ResultCheckFail
 	} else {
 		return antpost.ResultOK
 	}
 }
 
+// record keeps the first errors of broken responses and of error statuses.
+func (h *asyncHttpDrone) record(context *antpost.Context, response *AsyncHttpResposne, broken, status error) (error, error) {
+	if response.Err != nil {
+		if broken == nil {
+			broken = response.Err
+		}
+	} else {
+		recordResponse(context, response.StatusCode, len(response.Content))
+		if status == nil {
+			status = statusError(response.StatusCode)
+		}
+	}
+
+	return broken, status
+}
+
 func (h *asyncHttpDrone) Next() antpost.Drone {
 	return NewAsyncHttpDrone(h.http.Operator.NextSession())
 }
@@ -108,49 +158,119 @@ type asyncHttpRequest struct {
 	keepalive bool
 }
 
+// asyncConn is a *net.TCPConn or a *tls.Conn.
+type asyncConn interface {
+	net.Conn
+	CloseWrite() error
+}
+
 type asyncHttp struct {
-	conn  *net.TCPConn
-	wlock sync.Mutex
-	w     []*asyncHttpRequest
-	wc    chan bool
-	r     chan *AsyncHttpResposne
+	conn        asyncConn
+	wlock       sync.Mutex
+	w           []*asyncHttpRequest
+	sentMethods []string // of requests written, to frame the responses
+	werr        error    // of the write that failed, ending the connection
+	wc          chan bool
+	r           chan *AsyncHttpResposne
+	closed      chan bool
+
+	sent     int64 // on the wire, headers included
+	received int64
 }
 
-func newAsyncHttp(hostport string) (*asyncHttp, error) {
-	host, port, err := net.SplitHostPort(hostport)
+// newAsyncHttp connects to hostport, with TLS if config is not nil.
+func newAsyncHttp(ctx context.Context, hostport string, config *tls.Config) (*asyncHttp, error) {
+	conn, host, err := dialAsyncHttp(ctx, hostport, config != nil)
 	if err != nil {
-		host, port, err = net.SplitHostPort(hostport + ":80")
+		return nil, err
+	}
+
+	if config != nil {
+		conn, err = handshakeAsyncHttp(ctx, conn, host, config)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if len(port) == 0 {
-		port = "80"
+	return newAsyncHttpConn(ctx, conn), nil
+}
+
+func dialAsyncHttp(ctx context.Context, hostport string, https bool) (asyncConn, string, error) {
+	defaultPort := "80"
+	if https {
+		defaultPort = "443"
 	}
 
-	addr, err := net.ResolveTCPAddr("tcp", host+":"+port)
+	host, port, err := net.SplitHostPort(hostport)
 	if err != nil {
-		return nil, err
+		host, port, err = net.SplitHostPort(hostport + ":" + defaultPort)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(port) == 0 {
+		port = defaultPort
 	}
 
-	conn, err := net.DialTCP("tcp", nil, addr)
+	dialer := new(net.Dialer)
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
 	if err != nil {
+		return nil, "", err
+	}
+
+	return conn.(*net.TCPConn), host, nil
+}
+
+func handshakeAsyncHttp(ctx context.Context, conn asyncConn, host string, config *tls.Config) (asyncConn, error) {
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
+	if p := tlsConn.ConnectionState().NegotiatedProtocol; p != "" && p != "http/1.1" {
+		tlsConn.Close()
+		return nil, fmt.Errorf("tls: negotiated protocol %q is not http/1.1", p)
+	}
+
+	return tlsConn, nil
+}
+
+func newAsyncHttpConn(ctx context.Context, conn asyncConn) *asyncHttp {
 	c := new(asyncHttp)
 	c.conn = conn
 	c.w = make([]*asyncHttpRequest, 0)
 	c.wc = make(chan bool)
 	c.r = make(chan *AsyncHttpResposne)
+	c.closed = make(chan bool)
 	go c.goWrite()
 	go c.goRead()
-	return c, nil
+	go c.goCancel(ctx)
+	return c
+}
+
+// goCancel closes the connection once ctx is done, which ends goRead and
+// so the Response() channel.
+func (h *asyncHttp) goCancel(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		h.conn.Close()
+	case <-h.closed:
+	}
 }
 
+// Shutdown ends the requests, once those queued are written.
 func (h *asyncHttp) Shutdown() {
-	h.newReq(nil)
 	close(h.wc)
 }
 
@@ -172,14 +292,16 @@ func (h *asyncHttp) Do(url, method string, header http.Header, data []byte, keep
 func (h *asyncHttp) newReq(req *asyncHttpRequest) {
 	h.wlock.Lock()
 	defer h.wlock.Unlock()
-	if req != nil {
-		h.w = append(h.w, req)
-	} else {
-		h.w = nil
-	}
+	h.w = append(h.w, req)
 }
 
+// doWrite writes req, which is answered from now on, if only by the error
+// of the write.
 func (h *asyncHttp) doWrite(req *asyncHttpRequest) error {
+	h.wlock.Lock()
+	h.sentMethods = append(h.sentMethods, req.method)
+	h.wlock.Unlock()
+
 	host, path, err := parseUrl(req.url)
 	if err != nil {
 		return err
@@ -198,6 +320,41 @@ func (h *asyncHttp) doWrite(req *asyncHttpRequest) error {
 	return nil
 }
 
+// failPending answers the requests written but not answered, and the one
+// of the response being parsed if taken, with err, or else with the error
+// of the write that failed.
+func (h *asyncHttp) failPending(err error, taken bool) {
+	h.wlock.Lock()
+	n := len(h.sentMethods)
+	h.sentMethods = nil
+	if h.werr != nil {
+		err = h.werr
+	}
+
+	h.wlock.Unlock()
+
+	if taken {
+		n++
+	}
+
+	for i := 0; i < n; i++ {
+		h.r <- &AsyncHttpResposne{Err: err}
+	}
+}
+
+// sentMethod pops the method of the earliest request not yet answered.
+func (h *asyncHttp) sentMethod() string {
+	h.wlock.Lock()
+	defer h.wlock.Unlock()
+	if len(h.sentMethods) == 0 {
+		return ""
+	}
+
+	method := h.sentMethods[0]
+	h.sentMethods = h.sentMethods[1:]
+	return method
+}
+
 func (h *asyncHttp) Response() <-chan *AsyncHttpResposne {
 	return h.r
 }
@@ -214,8 +371,10 @@ func (h *asyncHttp) createHeader(method, path, host string, header http.Header,
 	}
 
 	if header != nil {
-		for k, v := range header {
-			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		for k, vs := range header {
+			for _, v := range vs {
+				fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+			}
 		}
 	}
 
@@ -236,8 +395,12 @@ func (h *asyncHttp) getReq() *asyncHttpRequest {
 	}
 }
 
+// goWrite writes requests until Shutdown. A failed write closes the
+// connection, so that goRead answers the requests pending with its error,
+// and the requests after it are dropped.
 func (h *asyncHttp) goWrite() {
 	defer h.conn.CloseWrite()
+	var failed error
 	for {
 		select {
 		case _, ok := <-h.wc:
@@ -246,11 +409,16 @@ func (h *asyncHttp) goWrite() {
 			}
 
 			req := h.getReq()
-			if req == nil {
+			if req == nil || failed != nil {
 				continue
 			}
 
-			h.doWrite(req)
+			if failed = h.doWrite(req); failed != nil {
+				h.wlock.Lock()
+				h.werr = failed
+				h.wlock.Unlock()
+				h.conn.Close()
+			}
 		}
 	}
 }
@@ -261,6 +429,7 @@ func (h *asyncHttp) write(data []byte) error {
 		n, err := h.conn.Write(data[sent:])
 		if n > 0 {
 			sent += n
+			atomic.AddInt64(&h.sent, int64(n))
 		}
 
 		if err != nil {
@@ -272,10 +441,11 @@ func (h *asyncHttp) write(data []byte) error {
 }
 
 func (h *asyncHttp) goRead() {
-	c := newChunkedHttpParse()
+	c := newHttpResponseParser(h.sentMethod)
+	defer close(h.closed)
 	defer h.conn.Close()
 	defer func() { close(h.r) }()
-	for !c.readOver {
+	for !c.eof {
 		s, err := h.read()
 		if len(s) > 0 {
 			c.write(s)
@@ -285,15 +455,17 @@ func (h *asyncHttp) goRead() {
 			if err == io.EOF {
 				c.write(nil)
 			} else {
+				h.failPending(err, c.taken())
 				return
 			}
 		}
 
 		for {
-			response, over := c.tryParse()
+			response, over := c.next()
 			if response != nil {
 				h.r <- response
 			} else if over {
+				h.failPending(io.ErrUnexpectedEOF, false)
 				return
 			} else {
 				break
@@ -307,6 +479,7 @@ func (h *asyncHttp) read() ([]byte, error) {
 	n, err := h.conn.Read(buf)
 	if n > 0 {
 		buf = buf[:n]
+		atomic.AddInt64(&h.received, int64(n))
 	} else {
 		buf = nil
 	}
@@ -322,151 +495,3 @@ func parseUrl(reqUrl string) (hostport, path string, err error) {
 
 	return u.Host, u.RequestURI(), nil
 }
-
-type chunkedHttpParse struct {
-	buf          *bytes.Buffer
-	statusCode   int
-	contentBytes int
-	chunked      *bytes.Buffer
-	readOver     bool
-}
-
-func newChunkedHttpParse() *chunkedHttpParse {
-	parse := new(chunkedHttpParse)
-	parse.buf = &bytes.Buffer{}
-	parse.contentBytes = -1
-	parse.chunked = nil
-	return parse
-}
-
-func (c *chunkedHttpParse) write(newBytes []byte) {
-	if len(newBytes) > 0 {
-		c.buf.Write(newBytes)
-	} else {
-		c.readOver = true
-	}
-}
-
-func (c *chunkedHttpParse) tryParse() (response *AsyncHttpResposne, tcpOver bool) {
-	if c.readOver && c.buf.Len() == 0 {
-		return nil, true
-	}
-
-	if c.contentBytes < 0 {
-		content := c.buf.Bytes()
-		rnrn := []byte{'\r', '\n', '\r', '\n'}
-		h := bytes.Index(content, rnrn)
-		if h >= 0 {
-			c.parseHeader(c.buf.Next(h + len(rnrn)))
-		} else {
-			return nil, false
-		}
-	}
-
-	if c.contentBytes == 0 {
-		response := &AsyncHttpResposne{c.statusCode, make([]byte, 0), nil}
-		c.statusCode = 0
-		c.contentBytes = -1
-		c.chunked = nil
-		return response, false
-	} else if c.chunked != nil {
-		finish, err := c.readChunked()
-		if err != nil {
-			// TODO:
-		}
-
-		if finish {
-			response := &AsyncHttpResposne{c.statusCode, c.chunked.Bytes(), nil}
-			c.statusCode = 0
-			c.contentBytes = -1
-			c.chunked = nil
-			return response, false
-		}
-	} else if (c.contentBytes > 0 && c.buf.Len() >= c.contentBytes) || c.readOver {
-		size := c.contentBytes
-		if size > c.buf.Len() {
-			size = c.buf.Len()
-		}
-
-		response := &AsyncHttpResposne{c.statusCode, c.buf.Next(size), nil}
-		c.statusCode = 0
-		c.contentBytes = -1
-		c.chunked = nil
-		return response, false
-	}
-
-	return nil, false
-}
-
-func (c *chunkedHttpParse) parseHeader(header []byte) error {
-	hs := bytes.Split(header, []byte{'\r', '\n'})
-	for i, h := range hs {
-		if i == 0 {
-			s := bytes.SplitN(h, []byte{' '}, 2)
-			if len(s) == 2 {
-				s = bytes.SplitN(bytes.TrimSpace(s[1]), []byte{' '}, 2)
-				if code, err := strconv.Atoi(string(bytes.TrimSpace(s[0]))); err == nil {
-					c.statusCode = code
-				}
-			}
-
-			continue
-		}
-
-		kv := bytes.SplitN(h, []byte{':'}, 2)
-		if len(kv) != 2 {
-			continue
-		}
-
-		switch strings.ToLower(strings.TrimSpace(string(kv[0]))) {
-		case "content-length":
-			length, err := strconv.Atoi(string(bytes.TrimSpace(kv[1])))
-			if err != nil || length < 0 {
-				return err
-			}
-
-			c.contentBytes = length
-		case "transfer-encoding":
-			if string(bytes.TrimSpace(kv[1])) == "chunked" {
-				c.chunked = &bytes.Buffer{}
-			}
-		}
-	}
-
-	if c.contentBytes == -1 {
-		c.contentBytes = math.MaxInt32
-	}
-
-	return nil
-}
-
-func (c *chunkedHttpParse) readChunked() (chunkedOver bool, err error) {
-	rn := []byte{'\r', '\n'}
-	for c.buf.Len() > 0 {
-		all := c.buf.Bytes()
-		p := bytes.Index(all, rn)
-		if p < 0 {
-			break
-		}
-
-		size, err := strconv.ParseUint(string(bytes.TrimSpace(all[:p])), 16, 32)
-		if err != nil || size < 0 {
-			return false, err
-		}
-
-		if len(all) >= p+len(rn)+int(size)+len(rn) {
-			c.buf.Next(p + len(rn))
-			if size > 0 {
-				c.chunked.Write(c.buf.Next(int(size)))
-			}
-			c.buf.Next(len(rn))
-			if size == 0 {
-				return true, nil
-			}
-		} else {
-			break
-		}
-	}
-
-	return false, nil
-}
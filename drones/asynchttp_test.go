@@ -5,10 +5,14 @@ import "testing"
 import (
 	"github.com/benbearchen/antpost"
 
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"time"
 )
 
@@ -42,16 +46,17 @@ func (h *Http) Run(addr string) {
 }
 
 func TestAsyncHttp(t *testing.T) {
-	addr := "localhost:8848"
-	go new(Http).Run(addr)
+	s := httptest.NewServer(new(Http))
+	defer s.Close()
+	addr := s.Listener.Addr().String()
 
-	async, err := newAsyncHttp(addr)
+	async, err := newAsyncHttp(context.Background(), addr, nil)
 	if err != nil {
 		t.Errorf("newAsyncHttp() failed: %v", err)
 		return
 	}
 
-	async.Get("http://localhost:8848/first", true)
+	async.Get("http://"+addr+"/first", true)
 	response, ok := <-async.Response()
 	if !ok {
 		t.Errorf("first meet close")
@@ -62,8 +67,8 @@ func TestAsyncHttp(t *testing.T) {
 		}
 	}
 
-	async.Get("http://localhost:8848/second", true)
-	async.Post("http://localhost:8848/", []byte("third"), false)
+	async.Get("http://"+addr+"/second", true)
+	async.Post("http://"+addr+"/", []byte("third"), false)
 	next := "/second"
 	for len(next) > 0 {
 		select {
@@ -122,7 +127,7 @@ func (a *asyncop) NextSession() *AsyncHttpSession {
 		shuffle[i] = datas[v]
 	}
 
-	return &AsyncHttpSession{host, newop(a.url, shuffle)}
+	return &AsyncHttpSession{host, newop(a.url, shuffle), nil}
 }
 
 func (a *asyncop) run() {
@@ -146,14 +151,124 @@ func (a *asyncop) run() {
 }
 
 func TestAsyncHttpDrone(t *testing.T) {
-	addr := "localhost:8849"
-	go new(Http).Run(addr)
+	s := httptest.NewServer(new(Http))
+	defer s.Close()
+	addr := s.Listener.Addr().String()
 
 	h := newop("http://"+addr+"/", nil).NextSession()
 	d := NewAsyncHttpDrone(h)
 	for i := 1; i <= 256; i *= 2 {
 		c := antpost.Run(d, i, 0, 15*time.Second)
-		fmt.Println("goroutines: ", i, c.Report()[0])
+		fmt.Println("goroutines: ", i, c.Report())
 		time.Sleep(time.Second * 1)
 	}
 }
+
+func TestAsyncHttpDroneBroken(t *testing.T) {
+	for reply, class := range map[string]antpost.ErrorClass{
+		"HTTP/1.1 200 OK\r\nContent-Length: 9\r\n\r\nshort": antpost.ErrorClosed,
+		"HTTP/1.1 200 OK\r\nContent-Length: x\r\n\r\n":      antpost.ErrorOther,
+		"HTTP/1.1 503 Busy\r\nContent-Length: 0\r\n\r\n":    antpost.ErrorHTTP5xx,
+	} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+
+		go func(reply string) {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				conn.Read(make([]byte, 1024))
+				conn.Write([]byte(reply))
+				conn.Close()
+			}
+		}(reply)
+
+		session := &AsyncHttpSession{l.Addr().String(), nil, nil}
+		session.Operator = newOnceOperator(session, "http://"+l.Addr().String()+"/")
+		r := antpost.Run(NewAsyncHttpDrone(session), 1, 1, 0).Report()
+		l.Close()
+		failed := r.Summary.ResponseBroken
+		if class == antpost.ErrorHTTP5xx {
+			failed = r.Summary.CheckFail
+		}
+
+		if failed != 1 || r.Errors == nil || len(r.Errors.Classes) != 1 || r.Errors.Classes[0].Class != class {
+			t.Errorf("AsyncHttpDrone with %q: %v, errors %v", reply, r.Summary, r.Errors)
+		}
+	}
+}
+
+// listOperator requests urls in one session, keeping it alive.
+type listOperator struct {
+	session *AsyncHttpSession
+	urls    []string
+	c       chan *AsyncHttpReq
+}
+
+func newListOperator(session *AsyncHttpSession, urls ...string) *listOperator {
+	o := &listOperator{session, urls, make(chan *AsyncHttpReq, len(urls))}
+	for _, url := range urls {
+		o.c <- &AsyncHttpReq{url, "GET", nil, nil, true}
+	}
+
+	close(o.c)
+	return o
+}
+
+func (o *listOperator) Response(context *antpost.Context, response *AsyncHttpResposne) {
+}
+
+func (o *listOperator) Next() <-chan *AsyncHttpReq {
+	return o.c
+}
+
+func (o *listOperator) NextSession() *AsyncHttpSession {
+	return &AsyncHttpSession{o.session.Host, newListOperator(o.session, o.urls...), o.session.TLS}
+}
+
+func TestAsyncHttpDronePending(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			// Answers only the first of two requests, then closes.
+			var got []byte
+			buf := make([]byte, 1024)
+			for strings.Count(string(got), "\r\n\r\n") < 2 {
+				n, err := conn.Read(buf)
+				if err != nil {
+					break
+				}
+
+				got = append(got, buf[:n]...)
+			}
+
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	url := "http://" + l.Addr().String() + "/"
+	for _, second := range []string{url, "http://[bad"} {
+		session := &AsyncHttpSession{l.Addr().String(), nil, nil}
+		session.Operator = newListOperator(session, url, second)
+		r := antpost.Run(NewAsyncHttpDrone(session), 1, 1, 0).Report()
+		if r.Summary.ResponseBroken != 1 || r.Errors == nil || len(r.Errors.Classes) != 1 {
+			t.Errorf("AsyncHttpDrone with %q unanswered: %v, errors %v", second, r.Summary, r.Errors)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+func newTLSServer() *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %d", r.URL.Path, len(r.TLS.PeerCertificates))
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	s.StartTLS()
+	return s
+}
+
+func TestAsyncHttps(t *testing.T) {
+	s := newTLSServer()
+	defer s.Close()
+	addr := s.Listener.Addr().String()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	config := &tls.Config{RootCAs: pool, ServerName: "example.com", Certificates: s.TLS.Certificates}
+	async, err := newAsyncHttp(context.Background(), addr, config)
+	if err != nil {
+		t.Errorf("newAsyncHttp() with TLS failed: %v", err)
+		return
+	}
+
+	async.Get("https://"+addr+"/first", false)
+	if response, ok := <-async.Response(); !ok || response.StatusCode != 200 || string(response.Content) != "/first 1" {
+		t.Errorf("newAsyncHttp() with TLS response: %v, %v", ok, response)
+	}
+
+	async.Shutdown()
+
+	if _, err := newAsyncHttp(context.Background(), addr, &tls.Config{}); antpost.ClassifyError(err) != antpost.ErrorTLS {
+		t.Errorf("newAsyncHttp() with an unknown CA should fail TLS: %v", err)
+	}
+
+	if _, err := newAsyncHttp(context.Background(), addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}}); err == nil {
+		t.Errorf("newAsyncHttp() should not negotiate h2")
+	}
+}
+
+// onceOperator sends one GET per session.
+type onceOperator struct {
+	session *AsyncHttpSession
+	url     string
+	c       chan *AsyncHttpReq
+}
+
+func newOnceOperator(session *AsyncHttpSession, url string) *onceOperator {
+	o := &onceOperator{session, url, make(chan *AsyncHttpReq, 1)}
+	o.c <- &AsyncHttpReq{url, "GET", nil, nil, false}
+	close(o.c)
+	return o
+}
+
+func (o *onceOperator) Response(context *antpost.Context, response *AsyncHttpResposne) {
+}
+
+func (o *onceOperator) Next() <-chan *AsyncHttpReq {
+	return o.c
+}
+
+func (o *onceOperator) NextSession() *AsyncHttpSession {
+	return &AsyncHttpSession{o.session.Host, newOnceOperator(o.session, o.url), o.session.TLS}
+}
+
+func TestAsyncHttpsDrone(t *testing.T) {
+	s := newTLSServer()
+	defer s.Close()
+
+	session := &AsyncHttpSession{s.Listener.Addr().String(), nil, &tls.Config{InsecureSkipVerify: true}}
+	session.Operator = newOnceOperator(session, s.URL+"/")
+	r := antpost.Run(NewAsyncHttpDrone(session), 1, 1, 0).Report()
+	if r.Summary.OK != 1 || r.HandshakeTime == nil || r.HandshakeTime.N != 1 || r.ResponseTime.N != 1 {
+		t.Errorf("AsyncHttpDrone with TLS report: %v", r)
+	}
+
+	if n := r.Stat.Nominals["status"]; n == nil || n.Items[0].Name != "200" {
+		t.Errorf("AsyncHttpDrone with TLS status: %v", r.Stat.Nominals)
+	}
+}
@@ -0,0 +1,204 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HttpCheck is a check of a response, of the one kind that is set: Status
+// ranges like "200,3xx,500-503", or "any", body Contains or Regex, Json
+// path Equals, or Json path present if Equals is empty, Header present, or
+// MaxBytes of body. Each check is recorded by Name, or else by what it
+// checks, as a Stat.Bool of the "checks" sub stat.
+type HttpCheck struct {
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Contains string `json:"contains,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	Json     string `json:"json,omitempty"`
+	Equals   string `json:"equals,omitempty"`
+	Header   string `json:"header,omitempty"`
+	MaxBytes int    `json:"max_bytes,omitempty"`
+
+	once   sync.Once
+	err    error
+	status [][2]int
+	regex  *regexp.Regexp
+}
+
+func (c *HttpCheck) compile() error {
+	c.once.Do(func() {
+		n := 0
+		for _, set := range []bool{c.Status != "", c.Contains != "", c.Regex != "", c.Json != "", c.Header != "", c.MaxBytes > 0} {
+			if set {
+				n++
+			}
+		}
+
+		if n != 1 {
+			c.err = fmt.Errorf("check %q should check exactly one thing", c.Name)
+		} else if c.Status != "" {
+			c.status, c.err = parseStatusRanges(c.Status)
+		} else if c.Regex != "" {
+			c.regex, c.err = regexp.Compile(c.Regex)
+		}
+
+		if c.Name == "" {
+			c.Name = c.describe()
+		}
+	})
+
+	return c.err
+}
+
+func (c *HttpCheck) describe() string {
+	switch {
+	case c.Status != "":
+		return "status " + c.Status
+	case c.Contains != "":
+		return "contains " + c.Contains
+	case c.Regex != "":
+		return "regex " + c.Regex
+	case c.Json != "" && c.Equals != "":
+		return "json " + c.Json + " = " + c.Equals
+	case c.Json != "":
+		return "json " + c.Json
+	case c.Header != "":
+		return "header " + c.Header
+	default:
+		return fmt.Sprintf("max bytes %d", c.MaxBytes)
+	}
+}
+
+// parseStatusRanges parses "200,3xx,500-503", or "any" for all codes.
+func parseStatusRanges(s string) ([][2]int, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "any") {
+		return [][2]int{{0, 999}}, nil
+	}
+
+	ranges := make([][2]int, 0)
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if len(r) == 3 && strings.HasSuffix(strings.ToLower(r), "xx") && r[0] >= '1' && r[0] <= '5' {
+			from := int(r[0]-'0') * 100
+			ranges = append(ranges, [2]int{from, from + 99})
+			continue
+		}
+
+		from, to := r, r
+		if i := strings.IndexByte(r, '-'); i > 0 {
+			from, to = r[:i], r[i+1:]
+		}
+
+		f, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("bad status range %q", r)
+		}
+
+		t, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || t < f {
+			return nil, fmt.Errorf("bad status range %q", r)
+		}
+
+		ranges = append(ranges, [2]int{f, t})
+	}
+
+	return ranges, nil
+}
+
+func (c *HttpCheck) check(statusCode int, header http.Header, data []byte) bool {
+	if c.compile() != nil {
+		return false
+	}
+
+	switch {
+	case c.status != nil:
+		for _, r := range c.status {
+			if statusCode >= r[0] && statusCode <= r[1] {
+				return true
+			}
+		}
+
+		return false
+	case c.Contains != "":
+		return strings.Contains(string(data), c.Contains)
+	case c.regex != nil:
+		return c.regex.Match(data)
+	case c.Json != "":
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return false
+		}
+
+		s, ok := jsonPath(v, c.Json)
+		return ok && (c.Equals == "" || s == c.Equals)
+	case c.Header != "":
+		_, ok := header[http.CanonicalHeaderKey(c.Header)]
+		return ok
+	default:
+		return len(data) <= c.MaxBytes
+	}
+}
+
+// checkResponse runs all checks, recording each, and returns why the first
+// failed one failed, or nil if all passed. Unless a check is of the status,
+// error statuses fail too.
+func checkResponse(context *antpost.Context, checks []*HttpCheck, statusCode int, header http.Header, data []byte) error {
+	var err error
+	status := false
+	if len(checks) > 0 {
+		stat := context.SubStat("checks")
+		for _, c := range checks {
+			ok := c.check(statusCode, header, data)
+			stat.Bool(c.Name, ok)
+			if !ok && err == nil {
+				err = checkError(c, statusCode)
+			}
+
+			status = status || c.Status != ""
+		}
+	}
+
+	if err == nil && !status {
+		err = statusError(statusCode)
+	}
+
+	return err
+}
+
+// checkError classifies a failed check by the status code, if that is an
+// error one.
+func checkError(c *HttpCheck, statusCode int) error {
+	err := fmt.Errorf("check %q failed on status %d", c.Name, statusCode)
+	if c.err != nil {
+		err = fmt.Errorf("check %q: %v", c.Name, c.err)
+	}
+
+	switch {
+	case statusCode >= 500:
+		return antpost.NewClassifiedError(antpost.ErrorHTTP5xx, err)
+	case statusCode >= 400:
+		return antpost.NewClassifiedError(antpost.ErrorHTTP4xx, err)
+	default:
+		return antpost.NewClassifiedError(antpost.ErrorCheck, err)
+	}
+}
+
+// statusError is the error of a 4xx or 5xx status code, or nil.
+func statusError(statusCode int) error {
+	switch {
+	case statusCode >= 500:
+		return antpost.NewClassifiedError(antpost.ErrorHTTP5xx, fmt.Errorf("status %d", statusCode))
+	case statusCode >= 400:
+		return antpost.NewClassifiedError(antpost.ErrorHTTP4xx, fmt.Errorf("status %d", statusCode))
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	for s, expect := range map[string]string{
+		"200":         "[[200 200]]",
+		"2xx, 404":    "[[200 299] [404 404]]",
+		"200-204,5XX": "[[200 204] [500 599]]",
+		"":            "error",
+		"299-200":     "error",
+		"6xx":         "error",
+		"200,abc":     "error",
+		"any":         "[[0 999]]",
+	} {
+		r, err := parseStatusRanges(s)
+		if got := fmt.Sprint(r); err != nil && expect != "error" || err == nil && got != expect {
+			t.Errorf("parseStatusRanges(%q) = %v, %v; expect %s", s, got, err, expect)
+		}
+	}
+}
+
+func TestHttpChecks(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace", "1")
+		if r.URL.Path == "/error" {
+			w.WriteHeader(500)
+			fmt.Fprint(w, "oops")
+			return
+		}
+
+		fmt.Fprint(w, `{"user": {"name": "ant"}}`)
+	}))
+	defer s.Close()
+
+	checks := []*HttpCheck{
+		{Status: "2xx"},
+		{Name: "named", Json: "user.name", Equals: "ant"},
+		{Contains: "ant"},
+		{Regex: `"na.e"`},
+		{Header: "x-trace"},
+		{MaxBytes: 100},
+	}
+
+	h := NewHttpGetReq(s.URL, nil, nil)
+	h.Checks = checks
+	r := antpost.Run(NewHttpDrone(h), 1, 2, 0).Report()
+	if r.Summary.OK != 2 || r.Summary.CheckFail != 0 {
+		t.Errorf("HttpReq.Checks on a good response: %v", r.Summary)
+	}
+
+	b := r.Stat.Subs["checks"].Bools
+	if len(b) != len(checks) || b["named"] == nil || b["status 2xx"] == nil || b["status 2xx"].True != 2 {
+		t.Errorf("HttpReq.Checks stats: %v", r.Stat.Subs["checks"])
+	}
+
+	h = NewHttpGetReq(s.URL+"/error", nil, nil)
+	h.Checks = checks
+	r = antpost.Run(NewHttpDrone(h), 1, 2, 0).Report()
+	b = r.Stat.Subs["checks"].Bools
+	if r.Summary.OK != 0 || r.Summary.CheckFail != 2 || b["status 2xx"].False != 2 || b["header x-trace"].True != 2 {
+		t.Errorf("HttpReq.Checks on a bad response: %v, %v", r.Summary, r.Stat.Subs["checks"])
+	}
+
+	if e := r.Errors; e == nil || len(e.Classes) != 1 || e.Classes[0].Class != antpost.ErrorHTTP5xx {
+		t.Errorf("HttpReq.Checks on a bad response errors: %v", e)
+	}
+
+	h = NewHttpGetReq(s.URL, nil, nil)
+	h.Checks = []*HttpCheck{{Status: "2xx", Contains: "ant"}}
+	if r := antpost.Run(NewHttpDrone(h), 1, 1, 0).Report(); r.Summary.CheckFail != 1 {
+		t.Errorf("HttpReq.Checks should fail a check of two things: %v", r.Summary)
+	}
+
+	r = antpost.Run(NewHttpDrone(NewHttpGetReq(s.URL+"/error", nil, nil)), 1, 1, 0).Report()
+	if e := r.Errors; r.Summary.CheckFail != 1 || e == nil || len(e.Classes) != 1 || e.Classes[0].Class != antpost.ErrorHTTP5xx {
+		t.Errorf("HttpDrone without checks on a 5xx response: %v, %v", r.Summary, e)
+	}
+
+	h = NewHttpGetReq(s.URL+"/error", nil, nil)
+	h.Checks = []*HttpCheck{{Contains: "oops"}}
+	r = antpost.Run(NewHttpDrone(h), 1, 1, 0).Report()
+	if e := r.Errors; r.Summary.CheckFail != 1 || e == nil || len(e.Classes) != 1 || e.Classes[0].Class != antpost.ErrorHTTP5xx {
+		t.Errorf("HttpReq.Checks without a status check on a 5xx response: %v, %v", r.Summary, e)
+	}
+
+	h = NewHttpGetReq(s.URL+"/error", nil, nil)
+	h.Checks = []*HttpCheck{{Status: "any"}}
+	if r := antpost.Run(NewHttpDrone(h), 1, 1, 0).Report(); r.Summary.OK != 1 {
+		t.Errorf("HttpReq.Checks of any status on a 5xx response: %v", r.Summary)
+	}
+}
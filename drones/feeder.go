@@ -0,0 +1,230 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type FeedMode int
+
+const (
+	FeedSequential FeedMode = iota // records in order, over and over
+	FeedRandom     FeedMode = iota // a random record each time
+	FeedUnique     FeedMode = iota // every record once, then no more
+)
+
+// Feeder hands out records of fields, to fill ${field} in requests. A
+// shared feeder hands out records to all workers of a run, while each
+// worker walks its own copy of a per worker one.
+type Feeder struct {
+	records   []map[string]string
+	mode      FeedMode
+	perWorker bool
+
+	lock sync.Mutex
+	next int
+}
+
+func NewFeeder(records []map[string]string, mode FeedMode, perWorker bool) *Feeder {
+	return &Feeder{records: records, mode: mode, perWorker: perWorker}
+}
+
+// LoadFeeder reads records from a CSV file, named *.csv, whose first row
+// names the fields, or else from a file of one JSON object per line.
+func LoadFeeder(path string, mode FeedMode, perWorker bool) (*Feeder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]string
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		records, err = readCSV(data)
+	} else {
+		records, err = readJSONL(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	} else if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no records", path)
+	}
+
+	return NewFeeder(records, mode, perWorker), nil
+}
+
+func ParseFeedMode(s string) (FeedMode, error) {
+	switch s {
+	case "sequential":
+		return FeedSequential, nil
+	case "random":
+		return FeedRandom, nil
+	case "unique":
+		return FeedUnique, nil
+	default:
+		return FeedSequential, fmt.Errorf("unknown feed mode %q", s)
+	}
+}
+
+func readCSV(data []byte) ([]map[string]string, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		r := make(map[string]string, len(row))
+		for i, name := range rows[0] {
+			r[name] = row[i]
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+func readJSONL(data []byte) ([]map[string]string, error) {
+	records := make([]map[string]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		r := make(map[string]string, len(fields))
+		for k, v := range fields {
+			r[k], _ = jsonPath(v, "")
+		}
+
+		records = append(records, r)
+	}
+
+	return records, scanner.Err()
+}
+
+// worker is the feeder for one more worker.
+func (f *Feeder) worker() *Feeder {
+	if f.perWorker {
+		return NewFeeder(f.records, f.mode, false)
+	} else {
+		return f
+	}
+}
+
+func (f *Feeder) feed() (map[string]string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch f.mode {
+	case FeedRandom:
+		return f.records[rand.Intn(len(f.records))], true
+	case FeedUnique:
+		if f.next >= len(f.records) {
+			return nil, false
+		}
+	}
+
+	r := f.records[f.next%len(f.records)]
+	f.next++
+	return r, true
+}
+
+// NewHttpFeedDrone runs h with ${field} in its Url, header values and Data
+// filled from a new record of f for every iteration. h.Next is not used.
+// Workers stop once a FeedUnique feeder runs out of records.
+func NewHttpFeedDrone(h *HttpReq, f *Feeder) antpost.Drone {
+	return &feedDrone{h, f, true, nil}
+}
+
+type feedDrone struct {
+	http   *HttpReq
+	feeder *Feeder
+	root   bool
+	req    *HttpReq
+}
+
+func (d *feedDrone) Run(context *antpost.Context) antpost.DroneResult {
+	if d.req == nil {
+		r, ok := d.feeder.feed()
+		if !ok {
+			context.Drop()
+			return antpost.ResultOK
+		}
+
+		d.req = d.http.expand(r)
+	}
+
+	return (&httpDrone{http: d.req}).Run(context)
+}
+
+func (d *feedDrone) Next() antpost.Drone {
+	f := d.feeder
+	if d.root {
+		f = f.worker()
+	}
+
+	r, ok := f.feed()
+	if !ok {
+		return nil
+	}
+
+	return &feedDrone{d.http, f, false, d.http.expand(r)}
+}
+
+// expand copies h with ${name} in its Url, header values and Data filled
+// from vars, labeled like h.
+func (h *HttpReq) expand(vars map[string]string) *HttpReq {
+	e := &HttpReq{fill(h.Url, vars), h.Method, nil, nil, nil, h.Arg, h.label(), h.Checks, h.Transport}
+	if h.Header != nil {
+		e.Header = make(http.Header, len(h.Header))
+		for k, values := range h.Header {
+			for _, v := range values {
+				e.Header.Add(k, fill(v, vars))
+			}
+		}
+	}
+
+	if h.Data != nil {
+		e.Data = []byte(fill(string(h.Data), vars))
+	}
+
+	return e
+}
+
+var placeholder = regexp.MustCompile(`\$\{([^{}]+)\}`)
+
+// fill replaces ${name} in s by vars[name], leaving other $ and the names
+// not in vars as they are.
+func fill(s string, vars map[string]string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := vars[m[2:len(m)-1]]; ok {
+			return v
+		}
+
+		return m
+	})
+}
@@ -0,0 +1,91 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+func TestLoadFeeder(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "users.csv")
+	jsonl := filepath.Join(dir, "users.jsonl")
+	ioutil.WriteFile(csv, []byte("id,name\n1,ant\n2,\"b, c\"\n"), 0644)
+	ioutil.WriteFile(jsonl, []byte("{\"id\": 1, \"name\": \"ant\"}\n\n{\"id\": 2, \"name\": \"b, c\"}\n"), 0644)
+	for _, path := range []string{csv, jsonl} {
+		f, err := LoadFeeder(path, FeedSequential, false)
+		if err != nil {
+			t.Errorf("LoadFeeder(%s) failed: %v", path, err)
+			continue
+		}
+
+		got := make([]string, 0)
+		for i := 0; i < 3; i++ {
+			r, _ := f.feed()
+			got = append(got, r["id"]+":"+r["name"])
+		}
+
+		if fmt.Sprint(got) != "[1:ant 2:b, c 1:ant]" {
+			t.Errorf("LoadFeeder(%s) records: %q", path, got)
+		}
+	}
+}
+
+func TestHttpFeedDrone(t *testing.T) {
+	lock := new(sync.Mutex)
+	var got []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		got = append(got, r.URL.Path+" "+r.Header.Get("X-User")+" "+string(data))
+		lock.Unlock()
+	}))
+	defer s.Close()
+
+	records := []map[string]string{{"id": "1", "name": "a"}, {"id": "2", "name": "b"}, {"id": "3", "name": "c"}}
+	h := &HttpReq{Url: s.URL + "/users/${id}", Method: "PUT", Header: http.Header{"X-User": {"${name}"}}, Data: []byte(`{"name": "${name}"}`)}
+	for _, perWorker := range []bool{false, true} {
+		got = nil
+		antpost.Run(NewHttpFeedDrone(h, NewFeeder(records, FeedUnique, perWorker)), 2, 0, 0)
+		sort.Strings(got)
+		expect := []string{`/users/1 a {"name": "a"}`, `/users/2 b {"name": "b"}`, `/users/3 c {"name": "c"}`}
+		if perWorker {
+			expect = []string{expect[0], expect[0], expect[1], expect[1], expect[2], expect[2]}
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(expect) {
+			t.Errorf("NewHttpFeedDrone(perWorker %v) requests %q != %q", perWorker, got, expect)
+		}
+	}
+
+	c := antpost.NewContext()
+	c.Start()
+	c.End(NewHttpFeedDrone(h, NewFeeder(nil, FeedUnique, false)).Run(c))
+	if r := c.Report(); r.Summary.N != 0 {
+		t.Errorf("NewHttpFeedDrone() out of records should drop the iteration: %v", r.Summary)
+	}
+}
+
+func TestFill(t *testing.T) {
+	vars := map[string]string{"id": "7", "1": "one"}
+	for s, expect := range map[string]string{
+		"/items/${id}":           "/items/7",
+		"price=$5&id=${id}":      "price=$5&id=7",
+		"$filter=name&$1":        "$filter=name&$1",
+		`{"a": "${missing}"}`:    `{"a": "${missing}"}`,
+		"${id}${1}$id ${id":      "7one$id ${id",
+		"no placeholders at all": "no placeholders at all",
+	} {
+		if got := fill(s, vars); got != expect {
+			t.Errorf("fill(%q) = %q; expect %q", s, got, expect)
+		}
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"github.com/benbearchen/antpost"
 
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
 )
 
 type NextHttp func(h *HttpReq, ok bool, statusCode int, header http.Header, data []byte) *HttpReq
@@ -18,14 +22,23 @@ type HttpReq struct {
 	Data   []byte
 	Next   NextHttp
 	Arg    interface{}
+	Label  string // of its iterations, the method and path of Url if empty
+
+	// Checks of the response, which fail an iteration with ResultCheckFail.
+	// Unless one is of the status, a 4xx or 5xx status does too.
+	Checks []*HttpCheck
+
+	// Transport is how it connects, as http.DefaultClient does if nil. Requests
+	// returned by Next keep it unless they set their own.
+	Transport *HttpTransport
 }
 
 func NewHttpGetReq(url string, next NextHttp, arg interface{}) *HttpReq {
-	return &HttpReq{url, "GET", nil, nil, next, arg}
+	return &HttpReq{url, "GET", nil, nil, next, arg, "", nil, nil}
 }
 
 func NewHttpPostReq(url string, data []byte, next NextHttp, arg interface{}) *HttpReq {
-	return &HttpReq{url, "POST", nil, data, next, arg}
+	return &HttpReq{url, "POST", nil, data, next, arg, "", nil, nil}
 }
 
 func NewHttpDrone(h *HttpReq) antpost.Drone {
@@ -39,26 +52,66 @@ type httpDrone struct {
 	next *HttpReq
 }
 
+// Run steps to StepConnected once it has a connection, new or reused, so
+// that the connect time is that of the transport alone. Bytes are those on
+// the wire, of failed requests too.
 func (h *httpDrone) Run(context *antpost.Context) antpost.DroneResult {
-	resp, err := h.http.req()
-	context.Step(antpost.StepConnected)
-	if err != nil {
+	context.Label(h.http.label())
+	recordRequest(context, len(h.http.Data))
+	wire := new(wireBytes)
+	defer func() {
+		context.Bytes(wire.load())
+	}()
+
+	connected := false
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			useConn(info.Conn, wire)
+			if !connected {
+				connected = true
+				context.Step(antpost.StepConnected)
+				context.Bool("reused", info.Reused)
+			}
+		},
+	}
+
+	client := h.http.Transport.client(context)
+	resp, err := h.http.req(httptrace.WithClientTrace(withWireBytes(context.Context(), wire), trace), client)
+	if err != nil && !connected {
+		context.Error(err)
 		return antpost.ResultConnectFail
+	} else if !connected {
+		context.Step(antpost.StepConnected)
+	}
+
+	if err != nil {
+		context.Error(err)
+		return antpost.ResultResponseBroken
 	}
 
 	defer resp.Body.Close()
 	data, err := ioutil.ReadAll(resp.Body)
 	context.Step(antpost.StepResponsed)
+	recordResponse(context, resp.StatusCode, len(data))
+
+	var checkErr error
+	if err == nil {
+		checkErr = checkResponse(context, h.http.Checks, resp.StatusCode, resp.Header, data)
+	}
 
 	if h.http.Next != nil {
-		ok := err == nil
+		ok := err == nil && checkErr == nil
 		h.next = h.http.Next(h.http, ok, resp.StatusCode, resp.Header, data)
 	}
 
 	context.Bool("conn", err == nil)
 
 	if err != nil {
+		context.Error(err)
 		return antpost.ResultResponseBroken
+	} else if checkErr != nil {
+		context.Error(checkErr)
+		return antpost.ResultCheckFail
 	} else {
 		return antpost.ResultOK
 	}
@@ -66,23 +119,53 @@ func (h *httpDrone) Run(context *antpost.Context) antpost.DroneResult {
 
 func (h *httpDrone) Next() antpost.Drone {
 	if h.next != nil {
-		return NewHttpDrone(h.next)
+		return NewHttpDrone(h.http.follow(h.next))
 	} else if h.http.Next != nil {
-		return NewHttpDrone(h.http.Next(h.http, false, 0, nil, nil))
+		return NewHttpDrone(h.http.follow(h.http.Next(h.http, false, 0, nil, nil)))
 	} else {
 		return h
 	}
 }
 
-func (h *HttpReq) req() (*http.Response, error) {
-	client := &http.Client{}
+// follow passes the transport of h on to next.
+func (h *HttpReq) follow(next *HttpReq) *HttpReq {
+	if next != nil && next.Transport == nil {
+		next.Transport = h.Transport
+	}
+
+	return next
+}
+
+// label is Label, or else the method and the path of Url, without the
+// query, so that requests to one endpoint share a label.
+func (h *HttpReq) label() string {
+	if h.Label != "" {
+		return h.Label
+	}
+
+	path := h.Url
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	if u, err := url.Parse(path); err == nil && u.Host != "" {
+		path = u.Path
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	return h.Method + " " + path
+}
 
+func (h *HttpReq) req(ctx context.Context, client *http.Client) (*http.Response, error) {
 	var body io.Reader = nil
-	if h.Method == "POST" {
+	if h.Data != nil {
 		body = bytes.NewReader(h.Data)
 	}
 
-	req, err := http.NewRequest(h.Method, h.Url, body)
+	req, err := http.NewRequestWithContext(ctx, h.Method, h.Url, body)
 	if err != nil {
 		return nil, err
 	}
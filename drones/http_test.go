@@ -0,0 +1,47 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func TestHttpDroneCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	c := antpost.RunContext(ctx, NewHttpDrone(NewHttpGetReq(s.URL, nil, nil)), 2, 0, 0)
+	if d := time.Now().Sub(begin); d > 2*time.Second {
+		t.Errorf("RunContext() should abort requests in flight, took %v", d)
+	}
+
+	r := c.Report()
+	if !r.Canceled || r.Time.N != 2 || r.OKTime.N != 0 {
+		t.Errorf("RunContext() canceled report: %v", r)
+	}
+}
+
+func TestHttpReqLabel(t *testing.T) {
+	for u, expect := range map[string]string{
+		"http://localhost:8080/users/1?token=abc": "GET /users/1",
+		"https://example.com":                     "GET /",
+		"http://example.com/a#top":                "GET /a",
+		"${host}/items?page=${page}":              "GET ${host}/items",
+		"http://${host}/items/${id}":              "GET http://${host}/items/${id}",
+	} {
+		if label := NewHttpGetReq(u, nil, nil).label(); label != expect {
+			t.Errorf("label of %q = %q; expect %q", u, label, expect)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package drones
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+const maxResponseHeaderBytes = 1 << 20
+
+type parseState int
+
+const (
+	parseHeader parseState = iota
+	parseBody
+	parseChunkSize
+	parseChunkData
+	parseChunkEnd
+	parseTrailer
+	parseUntilClose
+	parseDone
+)
+
+// httpResponseParser parses HTTP/1.x responses incrementally, as bytes
+// arrive in any split. Interim 1xx responses are skipped, and malformed
+// input ends the parse with a response carrying Err.
+type httpResponseParser struct {
+	buf    bytes.Buffer
+	eof    bool
+	state  parseState
+	method func() string // method of the request of the next response
+
+	response *AsyncHttpResposne
+	body     bytes.Buffer
+	left     int64
+	lines    []string
+}
+
+func newHttpResponseParser(method func() string) *httpResponseParser {
+	p := new(httpResponseParser)
+	p.method = method
+	return p
+}
+
+// write appends data, or marks the end of the stream if data is empty.
+func (p *httpResponseParser) write(data []byte) {
+	if len(data) > 0 {
+		p.buf.Write(data)
+	} else {
+		p.eof = true
+	}
+}
+
+// next returns the next complete response, or nil with over false if more
+// data is needed, or nil with over true if no response is left.
+func (p *httpResponseParser) next() (response *AsyncHttpResposne, over bool) {
+	for {
+		switch p.state {
+		case parseDone:
+			return nil, true
+		case parseHeader, parseTrailer:
+			line, ok := p.line()
+			if !ok {
+				if p.buf.Len() > maxResponseHeaderBytes {
+					return p.fail(errors.New("http: response header too large")), false
+				} else if !p.eof {
+					return nil, false
+				} else if p.state == parseHeader && p.response == nil && len(p.lines) == 0 && p.buf.Len() == 0 {
+					p.state = parseDone
+					return nil, true
+				}
+
+				return p.fail(io.ErrUnexpectedEOF), false
+			}
+
+			if len(line) > 0 {
+				if len(p.lines) == 0 && p.state == parseHeader && p.response == nil {
+					if err := p.statusLine(line); err != nil {
+						return p.fail(err), false
+					}
+				} else if err := p.headerLine(line); err != nil {
+					return p.fail(err), false
+				}
+
+				continue
+			}
+
+			if p.state == parseTrailer {
+				p.response.Header = p.merge(p.response.Header)
+				return p.finish(), false
+			} else if p.response == nil {
+				// Tolerates empty lines before the status line.
+				continue
+			}
+
+			if r, err := p.header(); err != nil {
+				return p.fail(err), false
+			} else if r != nil {
+				return r, false
+			}
+		case parseBody:
+			if p.buf.Len() == 0 {
+				if p.eof {
+					return p.fail(io.ErrUnexpectedEOF), false
+				}
+
+				return nil, false
+			}
+
+			n := min64(p.left, int64(p.buf.Len()))
+			p.body.Write(p.buf.Next(int(n)))
+			p.left -= n
+			if p.left == 0 {
+				return p.finish(), false
+			}
+		case parseUntilClose:
+			p.body.Write(p.buf.Next(p.buf.Len()))
+			if !p.eof {
+				return nil, false
+			}
+
+			r := p.finish()
+			p.state = parseDone
+			return r, false
+		case parseChunkSize:
+			line, ok := p.line()
+			if !ok {
+				if p.eof || p.buf.Len() > maxResponseHeaderBytes {
+					return p.fail(io.ErrUnexpectedEOF), false
+				}
+
+				return nil, false
+			}
+
+			size, err := chunkSize(line)
+			if err != nil {
+				return p.fail(err), false
+			}
+
+			if size == 0 {
+				p.state = parseTrailer
+			} else {
+				p.left = size
+				p.state = parseChunkData
+			}
+		case parseChunkData:
+			if p.buf.Len() == 0 {
+				if p.eof {
+					return p.fail(io.ErrUnexpectedEOF), false
+				}
+
+				return nil, false
+			}
+
+			n := min64(p.left, int64(p.buf.Len()))
+			p.body.Write(p.buf.Next(int(n)))
+			p.left -= n
+			if p.left == 0 {
+				p.state = parseChunkEnd
+			}
+		case parseChunkEnd:
+			line, ok := p.line()
+			if !ok {
+				if p.eof || p.buf.Len() >= 2 {
+					return p.fail(errors.New("http: missing CRLF after chunk data")), false
+				}
+
+				return nil, false
+			} else if len(line) > 0 {
+				return p.fail(errors.New("http: missing CRLF after chunk data")), false
+			}
+
+			p.state = parseChunkSize
+		}
+	}
+}
+
+// taken reports whether the response being parsed has taken the method of
+// its request.
+func (p *httpResponseParser) taken() bool {
+	return p.state != parseHeader && p.state != parseDone
+}
+
+// line takes one line off the buffer, ending with CRLF or a bare LF.
+func (p *httpResponseParser) line() (string, bool) {
+	i := bytes.IndexByte(p.buf.Bytes(), '\n')
+	if i < 0 {
+		return "", false
+	}
+
+	line := p.buf.Next(i + 1)
+	return string(bytes.TrimSuffix(line[:i], []byte{'\r'})), true
+}
+
+func (p *httpResponseParser) statusLine(line string) error {
+	proto, rest, _ := cut(line, " ")
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok || major != 1 {
+		return fmt.Errorf("http: malformed status line %q", line)
+	}
+
+	code, _, _ := cut(rest, " ")
+	statusCode, err := strconv.Atoi(code)
+	if err != nil || len(code) != 3 || statusCode < 100 {
+		return fmt.Errorf("http: malformed status code in %q", line)
+	}
+
+	p.response = &AsyncHttpResposne{statusCode, nil, nil, fmt.Sprintf("HTTP/%d.%d", major, minor), nil}
+	return nil
+}
+
+func (p *httpResponseParser) headerLine(line string) error {
+	if line[0] == ' ' || line[0] == '\t' {
+		// Obsolete line folding continues the previous field value.
+		if len(p.lines) == 0 {
+			return fmt.Errorf("http: malformed header line %q", line)
+		}
+
+		p.lines[len(p.lines)-1] += " " + strings.TrimSpace(line)
+		return nil
+	}
+
+	name, _, ok := cut(line, ":")
+	if !ok || len(name) == 0 || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("http: malformed header line %q", line)
+	}
+
+	p.lines = append(p.lines, line)
+	return nil
+}
+
+// merge adds the collected header lines to h.
+func (p *httpResponseParser) merge(h http.Header) http.Header {
+	if h == nil {
+		h = make(http.Header)
+	}
+
+	for _, line := range p.lines {
+		name, value, _ := cut(line, ":")
+		h.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value))
+	}
+
+	p.lines = nil
+	return h
+}
+
+// header decides the body length as RFC 9112 6.3 does, returning the
+// response if it has no body.
+func (p *httpResponseParser) header() (*AsyncHttpResposne, error) {
+	r := p.response
+	r.Header = p.merge(nil)
+	if r.StatusCode < 200 && r.StatusCode != http.StatusSwitchingProtocols {
+		p.response = nil
+		return nil, nil
+	}
+
+	method := ""
+	if p.method != nil {
+		method = p.method()
+	}
+
+	if r.StatusCode < 200 {
+		// The connection no longer speaks HTTP.
+		response := p.finish()
+		p.state = parseDone
+		return response, nil
+	} else if method == "HEAD" || r.StatusCode == http.StatusNoContent || r.StatusCode == http.StatusNotModified {
+		return p.finish(), nil
+	}
+
+	if te := r.Header.Values("Transfer-Encoding"); len(te) > 0 {
+		codings := strings.Split(strings.Join(te, ","), ",")
+		if strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked") {
+			p.state = parseChunkSize
+		} else {
+			p.state = parseUntilClose
+		}
+
+		return nil, nil
+	}
+
+	if cl := r.Header.Values("Content-Length"); len(cl) > 0 {
+		length := int64(-1)
+		for _, v := range strings.Split(strings.Join(cl, ","), ",") {
+			v = strings.TrimSpace(v)
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || strings.Trim(v, "0123456789") != "" || (length >= 0 && n != length) {
+				return nil, fmt.Errorf("http: invalid Content-Length %q", strings.Join(cl, ","))
+			}
+
+			length = n
+		}
+
+		if length == 0 {
+			return p.finish(), nil
+		}
+
+		p.left = length
+		p.state = parseBody
+		return nil, nil
+	}
+
+	p.state = parseUntilClose
+	return nil, nil
+}
+
+// finish returns the current response and starts the next.
+func (p *httpResponseParser) finish() *AsyncHttpResposne {
+	r := p.response
+	r.Content = append(make([]byte, 0, p.body.Len()), p.body.Bytes()...)
+	p.response = nil
+	p.body.Reset()
+	p.lines = nil
+	p.left = 0
+	p.state = parseHeader
+	return r
+}
+
+// fail ends the parse, as the rest of the stream can not be framed.
+func (p *httpResponseParser) fail(err error) *AsyncHttpResposne {
+	r := p.response
+	if r == nil {
+		r = &AsyncHttpResposne{}
+	}
+
+	r.Content = p.body.Bytes()
+	r.Err = err
+	p.response = nil
+	p.state = parseDone
+	return r
+}
+
+// chunkSize parses a chunk size line, ignoring chunk extensions.
+func chunkSize(line string) (int64, error) {
+	size, _, _ := cut(line, ";")
+	size = strings.TrimRight(size, " \t")
+	if len(size) == 0 || len(size) > 15 || strings.Trim(size, "0123456789abcdefABCDEF") != "" {
+		return 0, fmt.Errorf("http: invalid chunk size %q", line)
+	}
+
+	return strconv.ParseInt(size, 16, 64)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// cut slices s around the first sep, as strings.Cut of newer Go does.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
@@ -0,0 +1,108 @@
+package drones
+
+import "testing"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseResponses feeds input to a parser split every n bytes and formats
+// the responses it returns.
+func parseResponses(input string, methods []string, n int) []string {
+	p := newHttpResponseParser(func() string {
+		if len(methods) == 0 {
+			return ""
+		}
+
+		m := methods[0]
+		methods = methods[1:]
+		return m
+	})
+
+	out := make([]string, 0)
+	for data := []byte(input); ; {
+		if len(data) == 0 {
+			p.write(nil)
+		} else {
+			k := n
+			if k > len(data) {
+				k = len(data)
+			}
+
+			p.write(data[:k])
+			data = data[k:]
+		}
+
+		for {
+			r, over := p.next()
+			if over {
+				return out
+			} else if r == nil {
+				break
+			} else if r.Err != nil {
+				out = append(out, "error")
+			} else {
+				out = append(out, fmt.Sprintf("%d %s %q %s", r.StatusCode, r.Proto, r.Content, r.Header.Get("X-A")))
+			}
+		}
+	}
+}
+
+func TestHttpResponseParser(t *testing.T) {
+	tests := []struct {
+		input   string
+		methods []string
+		expect  string
+	}{
+		{"HTTP/1.1 200 OK\r\nContent-Length: 3\r\nX-A: a\r\n\r\nabc", nil, `200 HTTP/1.1 "abc" a`},
+		{"HTTP/1.0 200 OK\r\n\r\nuntil close", nil, `200 HTTP/1.0 "until close" `},
+		{"HTTP/1.1 200\nContent-Length: 1\n\nx", nil, `200 HTTP/1.1 "x" `},
+		{"HTTP/1.1 204 No Content\r\n\r\nHTTP/1.1 304 Not Modified\r\nContent-Length: 9\r\n\r\n", nil, `204 HTTP/1.1 "" |304 HTTP/1.1 "" `},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nab", []string{"HEAD", "GET"}, `200 HTTP/1.1 "" |200 HTTP/1.1 "ab" `},
+		{"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 103 Early Hints\r\nLink: </a>\r\n\r\nHTTP/1.1 201 Created\r\nContent-Length: 0\r\n\r\n", nil, `201 HTTP/1.1 "" `},
+		{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n2\r\nde\r\n0\r\nX-A: trailer\r\n\r\n", nil, `200 HTTP/1.1 "abcde" trailer`},
+		{"HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip, chunked\r\nContent-Length: 100\r\n\r\n1\r\nz\r\n0\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 1\r\n\r\nn", nil, `200 HTTP/1.1 "z" |404 HTTP/1.1 "n" `},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 2, 2\r\nX-A: a,\r\n b\r\n\r\nok", nil, `200 HTTP/1.1 "ok" a, b`},
+		{"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n\x81\x00", nil, `101 HTTP/1.1 "" `},
+		{"", nil, ``},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\nab", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nContent-Length: -1\r\n\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nab", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nBad header\r\n\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nBad name: x\r\n\r\n", nil, `error`},
+		{"HTTP/2 200\r\n\r\n", nil, `error`},
+		{"HTTP/1.1 20 OK\r\n\r\n", nil, `error`},
+		{"garbage\r\n\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 1\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nabc\r\n0\r\n\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nab\r\n", nil, `error`},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\nHTTP/1.1 oops\r\n\r\nHTTP/1.1 200 OK\r\n\r\n", nil, `200 HTTP/1.1 "" |error`},
+	}
+
+	for i, test := range tests {
+		for _, n := range []int{1, 2, 7, len(test.input) + 1} {
+			got := strings.Join(parseResponses(test.input, test.methods, n), "|")
+			if got != test.expect {
+				t.Errorf("#%d split %d: %s; expect %s", i, n, got, test.expect)
+			}
+		}
+	}
+}
+
+func FuzzHttpResponseParser(f *testing.F) {
+	f.Add("HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\nabc", 1)
+	f.Add("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n3;x\r\nabc\r\n0\r\nT: t\r\n\r\n", 3)
+	f.Add("HTTP/1.1 100 Continue\r\n\r\nHTTP/1.0 204 No Content\r\n\r\nHTTP/1.1 404\r\n\r\n", 5)
+	f.Fuzz(func(t *testing.T, input string, n int) {
+		if n <= 0 || n > len(input)+1 {
+			n = len(input) + 1
+		}
+
+		whole := strings.Join(parseResponses(input, []string{"GET", "HEAD"}, len(input)+1), "|")
+		if split := strings.Join(parseResponses(input, []string{"GET", "HEAD"}, n), "|"); split != whole {
+			t.Errorf("split %d: %s; whole: %s", n, split, whole)
+		}
+	})
+}
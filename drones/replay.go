@@ -0,0 +1,246 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayEntry is a recorded request, sent Offset after the first one.
+// Endpoint, the method and path, names it in the "endpoint" nominal stat.
+type ReplayEntry struct {
+	Req      *HttpReq
+	Offset   time.Duration
+	Endpoint string
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				Url     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// LoadHAR reads the requests of a HAR file, as saved by browsers.
+func LoadHAR(path string) ([]*ReplayEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	entries := make([]*ReplayEntry, 0, len(har.Log.Entries))
+	at := make([]time.Time, 0, len(har.Log.Entries))
+	for _, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.Url)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		h := &HttpReq{Url: e.Request.Url, Method: e.Request.Method, Label: e.Request.Method + " " + u.Path}
+		for _, header := range e.Request.Headers {
+			if strings.HasPrefix(header.Name, ":") || skipReplayHeader[http.CanonicalHeaderKey(header.Name)] {
+				continue
+			}
+
+			if h.Header == nil {
+				h.Header = make(http.Header)
+			}
+
+			h.Header.Add(header.Name, header.Value)
+		}
+
+		if e.Request.PostData != nil {
+			h.Data = []byte(e.Request.PostData.Text)
+		}
+
+		entries = append(entries, &ReplayEntry{h, 0, h.Label})
+		at = append(at, e.StartedDateTime)
+	}
+
+	return replayEntries(entries, at), nil
+}
+
+// skipReplayHeader are headers of the recorded connection, which the
+// replayed one sets anew.
+var skipReplayHeader = map[string]bool{"Host": true, "Content-Length": true, "Connection": true, "Accept-Encoding": true}
+
+var accessLogLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" \S+ \S+(?: "([^"]*)" "([^"]*)")?`)
+
+// LoadAccessLog reads the requests of an access log in the common or the
+// combined log format, to be sent to base, like "http://localhost:8080".
+// Lines of other formats are skipped.
+func LoadAccessLog(path string, base string) ([]*ReplayEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	base = strings.TrimSuffix(base, "/")
+	entries := make([]*ReplayEntry, 0)
+	at := make([]time.Time, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		m := accessLogLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+		if err != nil {
+			continue
+		}
+
+		h := &HttpReq{Url: base + m[3], Method: m[2]}
+		for _, header := range [][2]string{{"Referer", m[4]}, {"User-Agent", m[5]}} {
+			if header[1] != "" && header[1] != "-" {
+				if h.Header == nil {
+					h.Header = make(http.Header)
+				}
+
+				h.Header.Set(header[0], header[1])
+			}
+		}
+
+		p := m[3]
+		if i := strings.IndexByte(p, '?'); i >= 0 {
+			p = p[:i]
+		}
+
+		h.Label = h.Method + " " + p
+		entries = append(entries, &ReplayEntry{h, 0, h.Label})
+		at = append(at, t)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	} else if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no requests in common or combined log format", path)
+	}
+
+	return replayEntries(entries, at), nil
+}
+
+// replayEntries sorts entries by when they were sent, at, and sets their
+// offsets.
+func replayEntries(entries []*ReplayEntry, at []time.Time) []*ReplayEntry {
+	for i, e := range entries {
+		if i > 0 {
+			e.Offset = at[i].Sub(at[0])
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+	if len(entries) > 0 {
+		first := entries[0].Offset
+		for _, e := range entries {
+			e.Offset -= first
+		}
+	}
+
+	return entries
+}
+
+// NewReplayDrone sends entries once, in order, handing them out to the
+// workers of a run as they become free. With speed above 0 an entry is not
+// sent before its offset divided by speed, so 1 keeps the recorded timing
+// and 2 replays twice as fast, while 0 sends them all at full speed.
+func NewReplayDrone(entries []*ReplayEntry, speed float64) antpost.Drone {
+	return &replayDrone{replay: &replay{entries: entries, speed: speed}}
+}
+
+type replay struct {
+	entries []*ReplayEntry
+	speed   float64
+
+	lock  sync.Mutex
+	next  int
+	begin time.Time
+}
+
+func (r *replay) entry() (*ReplayEntry, time.Time, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.next >= len(r.entries) {
+		return nil, time.Time{}, false
+	}
+
+	if r.begin.IsZero() {
+		r.begin = time.Now()
+	}
+
+	e := r.entries[r.next]
+	r.next++
+	if r.speed <= 0 {
+		return e, r.begin, true
+	}
+
+	return e, r.begin.Add(time.Duration(float64(e.Offset) / r.speed)), true
+}
+
+type replayDrone struct {
+	replay *replay
+	entry  *ReplayEntry
+	at     time.Time // not to send entry before
+}
+
+// Run waits for the time of its entry, unless the run is over first.
+func (d *replayDrone) Run(context *antpost.Context) antpost.DroneResult {
+	if d.entry == nil {
+		e, at, ok := d.replay.entry()
+		if !ok {
+			context.Drop()
+			return antpost.ResultOK
+		}
+
+		d.entry, d.at = e, at
+	}
+
+	if !context.Wait(d.at) {
+		return antpost.ResultOK
+	}
+
+	context.Stat().Nominal("endpoint", d.entry.Endpoint)
+	return (&httpDrone{http: d.entry.Req}).Run(context)
+}
+
+// Next takes the next entry, or returns nil once all are taken.
+func (d *replayDrone) Next() antpost.Drone {
+	e, at, ok := d.replay.entry()
+	if !ok {
+		return nil
+	}
+
+	return &replayDrone{d.replay, e, at}
+}
@@ -0,0 +1,99 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	lock := new(sync.Mutex)
+	var got []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("User-Agent")+" "+string(data))
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	har := filepath.Join(dir, "site.har")
+	ioutil.WriteFile(har, []byte(`{"log": {"entries": [
+		{"startedDateTime": "2024-05-01T10:00:00.200Z", "request": {"method": "POST", "url": "`+s.URL+`/login", "headers": [{"name": ":authority", "value": "x"}, {"name": "User-Agent", "value": "ua"}], "postData": {"text": "u=ant"}}},
+		{"startedDateTime": "2024-05-01T10:00:00.000Z", "request": {"method": "GET", "url": "`+s.URL+`/?a=1", "headers": []}}
+	]}}`), 0644)
+
+	log := filepath.Join(dir, "access.log")
+	ioutil.WriteFile(log, []byte(`127.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /items?page=1 HTTP/1.1" 200 12
+not a log line
+127.0.0.1 - frank [01/May/2024:10:00:01 +0000] "GET /items?page=2 HTTP/1.1" 200 12 "-" "curl/8"
+`), 0644)
+
+	entries, err := LoadHAR(har)
+	if err != nil || len(entries) != 2 || entries[1].Offset != 200*time.Millisecond {
+		t.Errorf("LoadHAR() entries: %v, %v", entries, err)
+		return
+	}
+
+	begin := time.Now()
+	r := antpost.Run(NewReplayDrone(entries, 1), 1, 0, 0).Report()
+	if d := time.Now().Sub(begin); d < 200*time.Millisecond {
+		t.Errorf("NewReplayDrone(1) should keep the recorded timing, took %v", d)
+	}
+
+	if fmt.Sprint(got) != "[GET /?a=1 Go-http-client/1.1  POST /login ua u=ant]" {
+		t.Errorf("NewReplayDrone() requests: %q", got)
+	}
+
+	if n := r.Stat.Nominals["endpoint"]; n == nil || len(n.Items) != 2 {
+		t.Errorf("NewReplayDrone() endpoints: %v", r.Stat.Nominals)
+	}
+
+	if len(r.Labels) != 2 || r.Labels[0].Name != "GET /" || r.Labels[1].Name != "POST /login" {
+		t.Errorf("NewReplayDrone() labels: %v", r.Labels)
+	}
+
+	got = nil
+	entries, err = LoadAccessLog(log, s.URL+"/")
+	if err != nil || len(entries) != 2 || entries[1].Offset != time.Second || entries[1].Endpoint != "GET /items" {
+		t.Errorf("LoadAccessLog() entries: %v, %v", entries, err)
+		return
+	}
+
+	begin = time.Now()
+	antpost.Run(NewReplayDrone(entries, 0), 2, 0, 0)
+	if d := time.Now().Sub(begin); d > 500*time.Millisecond {
+		t.Errorf("NewReplayDrone(0) should replay at full speed, took %v", d)
+	}
+
+	if len(got) != 2 {
+		t.Errorf("NewReplayDrone() requests: %q", got)
+	}
+
+	got = nil
+	entries[1].Offset = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	begin = time.Now()
+	r = antpost.RunContext(ctx, NewReplayDrone(entries, 1), 2, 0, 0).Report()
+	if d := time.Now().Sub(begin); d > time.Second || len(got) != 1 || r.Summary.N != 1 || r.Summary.OK != 1 || !r.Canceled {
+		t.Errorf("NewReplayDrone() should drop the wait once canceled, took %v: %q, %v", d, got, r.Summary)
+	}
+
+	got = nil
+	begin = time.Now()
+	r = antpost.Run(NewReplayDrone(entries, 1), 2, 0, 200*time.Millisecond).Report()
+	if d := time.Now().Sub(begin); d > time.Second || len(got) != 1 || r.Summary.N != 1 || r.Summary.OK != 1 {
+		t.Errorf("NewReplayDrone() should drop the wait once out of time, took %v: %q, %v", d, got, r.Summary)
+	}
+}
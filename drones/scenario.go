@@ -0,0 +1,271 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scenario is a flow of HTTP steps, run in order by every worker and then
+// again from the first step. Urls, header values and bodies may refer to
+// variables as ${name}: ones set in Vars, or ones extracted from the
+// responses of earlier steps. A step that fails to connect, or to extract
+// its values, starts the flow over.
+//
+// In YAML, or in JSON with the same keys:
+//
+//	name: browse
+//	vars:
+//	  host: http://localhost:8080
+//	steps:
+//	  - name: login
+//	    method: POST
+//	    url: ${host}/login
+//	    header:
+//	      Content-Type: application/json
+//	    body: '{"user": "ant"}'
+//	    extract:
+//	      - var: token
+//	        json: data.token
+//	  - name: items
+//	    url: ${host}/items
+//	    header:
+//	      Authorization: Bearer ${token}
+//
+// YAML is read without flow collections, anchors or tags, so values that
+// start with [ or { need quotes.
+type Scenario struct {
+	Name  string            `json:"name"`
+	Vars  map[string]string `json:"vars"`
+	Steps []*ScenarioStep   `json:"steps"`
+
+	Transport *HttpTransport `json:"-"` // of all steps, set in code
+}
+
+type ScenarioStep struct {
+	Name    string             `json:"name"`   // labels its iterations, or else the method and url
+	Method  string             `json:"method"` // GET if empty
+	Url     string             `json:"url"`
+	Header  map[string]string  `json:"header"`
+	Body    string             `json:"body"`
+	Extract []*ScenarioExtract `json:"extract"`
+	Checks  []*HttpCheck       `json:"checks"`
+}
+
+// ScenarioExtract sets variable Var from a response by one of Json, a
+// dotted path like "data.items.0.id", Regex, matched against the body for
+// its first group or else the whole match, or Header, a header name.
+type ScenarioExtract struct {
+	Var    string `json:"var"`
+	Json   string `json:"json,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+	Header string `json:"header,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// LoadScenario reads a scenario file, as YAML if it is named *.yaml or
+// *.yml and as JSON otherwise.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *Scenario
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		s, err = ParseScenarioYAML(data)
+	default:
+		s, err = ParseScenarioJSON(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return s, nil
+}
+
+func ParseScenarioJSON(data []byte) (*Scenario, error) {
+	s := new(Scenario)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func ParseScenarioYAML(data []byte) (*Scenario, error) {
+	v, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(Scenario)
+	if err := decodeYAML(v, reflect.ValueOf(s)); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Drone compiles s into a drone, which fails if s is not valid.
+func (s *Scenario) Drone() (antpost.Drone, error) {
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %q has no steps", s.Name)
+	}
+
+	for i, step := range s.Steps {
+		if step.Url == "" {
+			return nil, fmt.Errorf("step %d %q has no url", i+1, step.Name)
+		}
+
+		for _, c := range step.Checks {
+			if err := c.compile(); err != nil {
+				return nil, fmt.Errorf("step %d %q: %v", i+1, step.Name, err)
+			}
+		}
+
+		for _, e := range step.Extract {
+			n := 0
+			for _, by := range []string{e.Json, e.Regex, e.Header} {
+				if by != "" {
+					n++
+				}
+			}
+
+			if e.Var == "" || n != 1 {
+				return nil, fmt.Errorf("step %d %q: extract needs a var and one of json, regex or header", i+1, step.Name)
+			}
+
+			if e.Regex != "" {
+				r, err := regexp.Compile(e.Regex)
+				if err != nil {
+					return nil, fmt.Errorf("step %d %q: %v", i+1, step.Name, err)
+				}
+
+				e.regex = r
+			}
+		}
+	}
+
+	return NewHttpDrone(s.req(0, s.Vars)), nil
+}
+
+// req builds step i with vars, to go on to the next step with the values
+// extracted from its response.
+func (s *Scenario) req(i int, vars map[string]string) *HttpReq {
+	step := s.Steps[i]
+	h := &HttpReq{Url: step.Url, Method: strings.ToUpper(step.Method), Label: step.Name, Transport: s.Transport, Checks: step.Checks}
+	if h.Method == "" {
+		h.Method = "GET"
+	}
+
+	if len(step.Header) > 0 {
+		h.Header = make(http.Header)
+		for k, v := range step.Header {
+			h.Header.Set(k, v)
+		}
+	}
+
+	if step.Body != "" {
+		h.Data = []byte(step.Body)
+	}
+
+	h = h.expand(vars)
+	h.Next = func(h *HttpReq, ok bool, statusCode int, header http.Header, data []byte) *HttpReq {
+		if !ok {
+			return s.req(0, s.Vars)
+		}
+
+		next := make(map[string]string, len(vars)+len(step.Extract))
+		for k, v := range vars {
+			next[k] = v
+		}
+
+		for _, e := range step.Extract {
+			v, found := e.extract(header, data)
+			if !found {
+				return s.req(0, s.Vars)
+			}
+
+			next[e.Var] = v
+		}
+
+		if i+1 < len(s.Steps) {
+			return s.req(i+1, next)
+		} else {
+			return s.req(0, s.Vars)
+		}
+	}
+
+	return h
+}
+
+func (e *ScenarioExtract) extract(header http.Header, data []byte) (string, bool) {
+	if e.Header != "" {
+		v := header.Get(e.Header)
+		return v, v != ""
+	} else if e.regex != nil {
+		m := e.regex.FindSubmatch(data)
+		if m == nil {
+			return "", false
+		} else if len(m) > 1 {
+			return string(m[1]), true
+		} else {
+			return string(m[0]), true
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", false
+	}
+
+	return jsonPath(v, e.Json)
+}
+
+// jsonPath finds the value at a dotted path of keys and array indexes in v,
+// as JSON unless it is a string.
+func jsonPath(v interface{}, path string) (string, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch x := v.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if v, ok = x[key]; !ok {
+					return "", false
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(x) {
+					return "", false
+				}
+
+				v = x[i]
+			default:
+				return "", false
+			}
+		}
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return x, true
+	default:
+		data, err := json.Marshal(x)
+		return string(data), err == nil
+	}
+}
@@ -0,0 +1,139 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+)
+
+func TestScenario(t *testing.T) {
+	lock := new(sync.Mutex)
+	got := make([]string, 0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		lock.Unlock()
+
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("X-Session", "s1")
+			fmt.Fprint(w, `{"data": {"token": "abc", "items": [{"id": 7}]}}`)
+		case "/items/7":
+			fmt.Fprint(w, `<a href="/next?page=2">`)
+		}
+	}))
+	defer s.Close()
+
+	sc, err := ParseScenarioJSON([]byte(`{
+		"name": "browse",
+		"vars": {"host": "` + s.URL + `"},
+		"steps": [
+			{
+				"name": "login",
+				"method": "post",
+				"url": "${host}/login",
+				"body": "{\"user\": \"ant\"}",
+				"extract": [
+					{"var": "token", "json": "$.data.token"},
+					{"var": "item", "json": "data.items.0.id"},
+					{"var": "session", "header": "X-Session"}
+				]
+			},
+			{
+				"url": "${host}/items/${item}",
+				"header": {"Authorization": "Bearer ${token} ${session}"},
+				"extract": [{"var": "page", "regex": "page=(\\d+)"}]
+			},
+			{"url": "${host}/page/${page}"}
+		]
+	}`))
+	if err != nil {
+		t.Errorf("ParseScenarioJSON() failed: %v", err)
+		return
+	}
+
+	d, err := sc.Drone()
+	if err != nil {
+		t.Errorf("Scenario.Drone() failed: %v", err)
+		return
+	}
+
+	antpost.Run(d, 1, 4, 0)
+	expect := []string{"POST /login ", "GET /items/7 Bearer abc s1", "GET /page/2 ", "POST /login "}
+	if fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Errorf("Scenario requests %q != %q", got, expect)
+	}
+
+	if _, err := (&Scenario{Steps: []*ScenarioStep{{Url: "/", Extract: []*ScenarioExtract{{Var: "v"}}}}}).Drone(); err == nil {
+		t.Errorf("Scenario.Drone() should fail on an extract without a source")
+	}
+}
+
+func TestParseScenarioYAML(t *testing.T) {
+	y, err := ParseScenarioYAML([]byte(`
+# a flow
+name: browse
+vars:
+  host: http://localhost:8080
+steps:
+  - name: login
+    method: post
+    url: ${host}/login
+    body: '{"user": "ant"}'
+    extract:
+      - var: token
+        json: $.data.token
+      - var: page
+        regex: page=(\d+)  # of the next link
+    checks:
+      - status: 2xx
+      - max_bytes: 1024
+  - url: "${host}/items"
+    header:
+      Authorization: Bearer ${token}
+`))
+	if err != nil {
+		t.Fatalf("ParseScenarioYAML() failed: %v", err)
+	}
+
+	j, err := ParseScenarioJSON([]byte(`{
+		"name": "browse",
+		"vars": {"host": "http://localhost:8080"},
+		"steps": [
+			{
+				"name": "login",
+				"method": "post",
+				"url": "${host}/login",
+				"body": "{\"user\": \"ant\"}",
+				"extract": [
+					{"var": "token", "json": "$.data.token"},
+					{"var": "page", "regex": "page=(\\d+)"}
+				],
+				"checks": [{"status": "2xx"}, {"max_bytes": 1024}]
+			},
+			{
+				"url": "${host}/items",
+				"header": {"Authorization": "Bearer ${token}"}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseScenarioJSON() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(y, j) {
+		t.Errorf("ParseScenarioYAML() %+v != JSON %+v", y, j)
+	}
+
+	for _, bad := range []string{"steps: [{url: /}]", "steps:\n  - url: /\n   name: x", "vars:\n\thost: x", "steps: x", "steps:\n  - checks:\n      - max_bytes: many"} {
+		if _, err := ParseScenarioYAML([]byte(bad)); err == nil {
+			t.Errorf("ParseScenarioYAML(%q) should fail", bad)
+		}
+	}
+}
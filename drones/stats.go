@@ -0,0 +1,28 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"strconv"
+)
+
+var statusClasses = antpost.NewOrdinalGen("1xx", "2xx", "3xx", "4xx", "5xx")
+
+// recordRequest records the body size of a request as the "request bytes"
+// ratio.
+func recordRequest(context *antpost.Context, size int) {
+	context.Stat().Ratio("request bytes", float64(size))
+}
+
+// recordResponse records the status code as the "status" nominal, its
+// class as the "status class" ordinal, and the body size as the "response
+// bytes" ratio.
+func recordResponse(context *antpost.Context, statusCode int, size int) {
+	stat := context.Stat()
+	stat.Nominal("status", strconv.Itoa(statusCode))
+	if statusCode >= 100 && statusCode < 600 {
+		stat.Ordinal("status class", statusClasses.Ord(strconv.Itoa(statusCode/100)+"xx"))
+	}
+
+	stat.Ratio("response bytes", float64(size))
+}
@@ -0,0 +1,70 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+func TestHttpStats(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+		}
+
+		fmt.Fprint(w, "hello")
+	}))
+	defer s.Close()
+
+	next := func(h *HttpReq, ok bool, statusCode int, header http.Header, data []byte) *HttpReq {
+		if h.Url == s.URL {
+			return NewHttpGetReq(s.URL+"/missing", h.Next, nil)
+		}
+
+		return NewHttpPostReq(s.URL, []byte("abc"), h.Next, nil)
+	}
+
+	r := antpost.Run(NewHttpDrone(NewHttpPostReq(s.URL, []byte("abc"), next, nil)), 1, 4, 0).Report()
+	// Wire bytes, with the request and status lines and the headers.
+	if r.Summary.BytesSent < int64(4*len("GET / HTTP/1.1\r\n\r\n")+6) || r.Summary.BytesReceived < int64(4*len("HTTP/1.1 200 OK\r\n\r\n")+20) {
+		t.Errorf("HttpDrone bytes: %v", r.Summary)
+	}
+
+	st := r.Stat
+	status := make(map[string]int)
+	for _, item := range st.Nominals["status"].Items {
+		status[item.Name] = item.N
+	}
+
+	if status["200"] != 2 || status["404"] != 2 {
+		t.Errorf("HttpDrone status: %v", status)
+	}
+
+	classes := make(map[string]int)
+	for _, rank := range st.Ordinals["status class"].Ranks {
+		classes[rank.Name] = rank.N
+	}
+
+	if classes["2xx"] != 2 || classes["4xx"] != 2 || classes["5xx"] != 0 {
+		t.Errorf("HttpDrone status class: %v", classes)
+	}
+
+	if st.Ratios["request bytes"].N != 4 || st.Ratios["request bytes"].Max != 3 || st.Ratios["response bytes"].Mean != 5 {
+		t.Errorf("HttpDrone bytes ratios: %v, %v", st.Ratios["request bytes"], st.Ratios["response bytes"])
+	}
+}
+
+func TestHttpStatsFailed(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := s.URL
+	s.Close()
+
+	r := antpost.Run(NewHttpDrone(NewHttpPostReq(url, []byte("abc"), nil, nil)), 1, 2, 0).Report()
+	if r.Summary.ConnectFail != 2 || r.Stat.Ratios["request bytes"] == nil || r.Stat.Ratios["request bytes"].N != 2 {
+		t.Errorf("HttpDrone failed requests: %v, %v", r.Summary, r.Stat.Ratios)
+	}
+}
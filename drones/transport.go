@@ -0,0 +1,208 @@
+package drones
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultClient is http.DefaultClient, but with the connections counting
+// their bytes.
+var defaultClient = newDefaultClient()
+
+func newDefaultClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = countDial(transport.DialContext)
+	return &http.Client{Transport: transport}
+}
+
+// HttpTransport sets how the requests of an HttpReq connect. The zero
+// value shares one pool of keep-alive connections among all workers, with
+// no limits and no timeouts.
+type HttpTransport struct {
+	PerWorker           bool // a pool of connections for each worker, instead of one for all
+	DisableKeepAlive    bool // a new connection for every request
+	MaxConnsPerHost     int  // 0 for no limit
+	MaxIdleConnsPerHost int  // 0 for MaxConnsPerHost, or 100 if that is 0 too
+
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	ResponseTimeout     time.Duration // from the request written to the response header
+	Timeout             time.Duration // of the whole request, response body included
+
+	HTTP2              bool // negotiate HTTP/2 over TLS
+	InsecureSkipVerify bool
+
+	lock    sync.Mutex
+	shared  *http.Client
+	workers map[*antpost.Context]*http.Client
+}
+
+// client is the client for the worker running with c.
+func (t *HttpTransport) client(c *antpost.Context) *http.Client {
+	if t == nil {
+		return defaultClient
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.PerWorker {
+		if t.shared == nil {
+			t.shared = t.newClient()
+		}
+
+		return t.shared
+	}
+
+	if t.workers == nil {
+		t.workers = make(map[*antpost.Context]*http.Client)
+	}
+
+	client, ok := t.workers[c]
+	if !ok {
+		client = t.newClient()
+		t.workers[c] = client
+		c.Defer(func() {
+			t.release(c)
+		})
+	}
+
+	return client
+}
+
+// release drops the pool of the worker running with c, once it is done.
+func (t *HttpTransport) release(c *antpost.Context) {
+	t.lock.Lock()
+	client := t.workers[c]
+	delete(t.workers, c)
+	t.lock.Unlock()
+
+	if client != nil {
+		client.CloseIdleConnections()
+	}
+}
+
+func (t *HttpTransport) newClient() *http.Client {
+	idle := t.MaxIdleConnsPerHost
+	if idle <= 0 {
+		idle = t.MaxConnsPerHost
+	}
+
+	if idle <= 0 {
+		idle = 100
+	}
+
+	dialer := &net.Dialer{Timeout: t.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           countDial(dialer.DialContext),
+		DisableKeepAlives:     t.DisableKeepAlive,
+		MaxConnsPerHost:       t.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   idle,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   t.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: t.ResponseTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     t.HTTP2,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify},
+	}
+
+	if !t.HTTP2 {
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return &http.Client{Transport: transport, Timeout: t.Timeout}
+}
+
+// CloseIdleConnections closes the idle connections of every pool of t.
+// Those of workers are dropped as each worker is done anyway.
+func (t *HttpTransport) CloseIdleConnections() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.shared != nil {
+		t.shared.CloseIdleConnections()
+	}
+
+	for _, client := range t.workers {
+		client.CloseIdleConnections()
+	}
+}
+
+// wireBytes are the bytes an iteration sent and received on its
+// connections, TLS handshakes and headers included.
+type wireBytes struct {
+	sent     int64
+	received int64
+}
+
+type wireBytesKey struct{}
+
+// withWireBytes lets connections dialed for requests with ctx count their
+// bytes to w.
+func withWireBytes(ctx context.Context, w *wireBytes) context.Context {
+	return context.WithValue(ctx, wireBytesKey{}, w)
+}
+
+func (w *wireBytes) load() (int64, int64) {
+	return atomic.LoadInt64(&w.sent), atomic.LoadInt64(&w.received)
+}
+
+// countingConn counts its bytes to the iteration that used it last, which
+// over HTTP/2 is only roughly the one they belong to.
+type countingConn struct {
+	net.Conn
+	owner atomic.Value
+}
+
+func countDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &countingConn{Conn: conn}
+		if w, ok := ctx.Value(wireBytesKey{}).(*wireBytes); ok {
+			c.owner.Store(w)
+		}
+
+		return c, nil
+	}
+}
+
+// useConn counts the bytes of conn, as got by a request, to w.
+func useConn(conn net.Conn, w *wireBytes) {
+	if t, ok := conn.(*tls.Conn); ok {
+		conn = t.NetConn()
+	}
+
+	if c, ok := conn.(*countingConn); ok {
+		c.owner.Store(w)
+	}
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if w, ok := c.owner.Load().(*wireBytes); ok {
+		atomic.AddInt64(&w.received, int64(n))
+	}
+
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if w, ok := c.owner.Load().(*wireBytes); ok {
+		atomic.AddInt64(&w.sent, int64(n))
+	}
+
+	return n, err
+}
@@ -0,0 +1,49 @@
+package drones
+
+import "testing"
+
+import (
+	"github.com/benbearchen/antpost"
+
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+func TestHttpTransport(t *testing.T) {
+	lock := new(sync.Mutex)
+	conns := make(map[string]bool)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		conns[r.RemoteAddr] = true
+		lock.Unlock()
+	}))
+	defer s.Close()
+
+	for _, c := range []struct {
+		transport *HttpTransport
+		conns     int
+	}{
+		{&HttpTransport{MaxConnsPerHost: 1}, 1},
+		{&HttpTransport{PerWorker: true}, 2},
+		{&HttpTransport{DisableKeepAlive: true}, 10},
+	} {
+		conns = make(map[string]bool)
+		h := NewHttpGetReq(s.URL, nil, nil)
+		h.Transport = c.transport
+		r := antpost.Run(NewHttpDrone(h), 2, 5, 0).Report()
+		if len(c.transport.workers) != 0 {
+			t.Errorf("HttpTransport %+v kept %d pools of done workers", c.transport, len(c.transport.workers))
+		}
+
+		c.transport.CloseIdleConnections()
+		if len(conns) != c.conns {
+			t.Errorf("HttpTransport %+v made %d connections, not %d", c.transport, len(conns), c.conns)
+		}
+
+		reused := r.Stat.Bools["reused"]
+		if r.Summary.OK != 10 || reused == nil || reused.False != c.conns {
+			t.Errorf("HttpTransport %+v report: %v, %v", c.transport, r.Summary, reused)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package drones
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of YAML, numbered from 1, with its indent apart.
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+	raw    string
+}
+
+// yamlParser parses the subset of YAML that scenarios need: block mappings
+// and sequences, plain and quoted scalars, | and > block scalars, and
+// comments. Flow collections, anchors, aliases, tags and multiple documents
+// are not supported.
+type yamlParser struct {
+	lines []*yamlLine
+	i     int
+}
+
+// parseYAML parses data into map[string]interface{}, []interface{} and
+// string values, with nil for empty and null ones.
+func parseYAML(data []byte) (interface{}, error) {
+	p := new(yamlParser)
+	for i, raw := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs can not indent", i+1)
+		}
+
+		p.lines = append(p.lines, &yamlLine{i + 1, len(raw) - len(text), strings.TrimRight(text, " \t"), raw})
+	}
+
+	p.skip()
+	if p.i < len(p.lines) && p.lines[p.i].text == "---" {
+		p.i++
+		p.skip()
+	}
+
+	if p.i >= len(p.lines) {
+		return nil, nil
+	}
+
+	v, err := p.node(p.lines[p.i].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skip()
+	if p.i < len(p.lines) {
+		return nil, p.errorf("unexpected indent")
+	}
+
+	return v, nil
+}
+
+func (p *yamlParser) errorf(format string, a ...interface{}) error {
+	n := len(p.lines)
+	if p.i < len(p.lines) {
+		n = p.lines[p.i].n
+	}
+
+	return fmt.Errorf("yaml: line %d: %s", n, fmt.Sprintf(format, a...))
+}
+
+// skip skips empty and comment lines.
+func (p *yamlParser) skip() {
+	for p.i < len(p.lines) && (p.lines[p.i].text == "" || p.lines[p.i].text[0] == '#') {
+		p.i++
+	}
+}
+
+// node parses the sequence, mapping or scalar that starts at the current
+// line, indented by indent.
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	l := p.lines[p.i]
+	if isYAMLItem(l.text) {
+		return p.sequence(indent)
+	} else if _, _, ok := yamlKey(l.text); ok {
+		return p.mapping(indent)
+	}
+
+	p.i++
+	v, err := yamlScalar(l.text)
+	if err != nil {
+		p.i--
+		return nil, p.errorf("%v", err)
+	}
+
+	return v, nil
+}
+
+// nested parses the node indented deeper than indent, if there is one.
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	p.skip()
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return p.node(p.lines[p.i].indent)
+	}
+
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]interface{}, error) {
+	seq := make([]interface{}, 0)
+	for p.skip(); p.i < len(p.lines); p.skip() {
+		l := p.lines[p.i]
+		if l.indent < indent || (l.indent == indent && !isYAMLItem(l.text)) {
+			break
+		} else if l.indent > indent {
+			return nil, p.errorf("unexpected indent")
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v interface{}
+		var err error
+		if rest == "" || rest[0] == '#' {
+			p.i++
+			v, err = p.nested(indent)
+		} else {
+			// The item goes on as a node indented like its content.
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			v, err = p.node(l.indent)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		seq = append(seq, v)
+	}
+
+	return seq, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.skip(); p.i < len(p.lines); p.skip() {
+		l := p.lines[p.i]
+		if l.indent < indent {
+			break
+		} else if l.indent > indent || isYAMLItem(l.text) {
+			return nil, p.errorf("unexpected indent")
+		}
+
+		key, rest, ok := yamlKey(l.text)
+		if !ok {
+			return nil, p.errorf("expected a key in %q", l.text)
+		} else if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		p.i++
+		var v interface{}
+		var err error
+		switch {
+		case rest == "" || rest[0] == '#':
+			// A sequence may go on at the indent of its key.
+			p.skip()
+			if p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+				v, err = p.sequence(indent)
+			} else {
+				v, err = p.nested(indent)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			v, err = p.block(indent, rest)
+		default:
+			v, err = yamlScalar(rest)
+			if err != nil {
+				err = fmt.Errorf("yaml: line %d: %v", l.n, err)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		m[key] = v
+	}
+
+	return m, nil
+}
+
+// block parses a literal | or folded > block scalar, with its header, after
+// a key indented by indent.
+func (p *yamlParser) block(indent int, header string) (string, error) {
+	if i := strings.Index(header, " #"); i >= 0 {
+		header = header[:i]
+	}
+
+	header = strings.TrimSpace(header)
+	style, chomp := header[0], header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", p.errorf("unsupported block scalar header %q", header)
+	}
+
+	lines := make([]string, 0)
+	content := -1
+	for ; p.i < len(p.lines); p.i++ {
+		l := p.lines[p.i]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			continue
+		} else if l.indent <= indent || (content >= 0 && l.indent < content) {
+			break
+		} else if content < 0 {
+			content = l.indent
+		}
+
+		lines = append(lines, l.raw[content:])
+	}
+
+	n := len(lines)
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+
+	var b strings.Builder
+	for i, line := range lines[:n] {
+		if style == '|' && i > 0 {
+			b.WriteString("\n")
+		} else if style == '>' && line == "" {
+			b.WriteString("\n")
+		} else if i > 0 && lines[i-1] != "" {
+			b.WriteString(" ")
+		}
+
+		b.WriteString(line)
+	}
+
+	s := b.String()
+	if n > 0 && chomp == "+" {
+		s += strings.Repeat("\n", len(lines)-n+1)
+	} else if n > 0 && chomp == "" {
+		s += "\n"
+	}
+
+	return s, nil
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKey splits "key: value" into the key, unquoted, and the rest.
+func yamlKey(text string) (string, string, bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return "", "", false
+		}
+
+		after := text[end+1:]
+		if !strings.HasPrefix(after, ":") || (len(after) > 1 && after[1] != ' ') {
+			return "", "", false
+		}
+
+		key, err := yamlScalar(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+
+		return key.(string), strings.TrimSpace(after[1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimRight(text[:i], " "), strings.TrimSpace(text[i+1:]), i > 0
+		} else if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			break
+		}
+	}
+
+	return "", "", false
+}
+
+// yamlQuoteEnd is the index of the quote closing the one s starts with, or
+// -1.
+func yamlQuoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[0] == '"' && s[i] == '\\' {
+			i++
+		} else if s[i] == s[0] && s[0] == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+			i++
+		} else if s[i] == s[0] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// yamlScalar parses a scalar on one line, plain or quoted, and maybe
+// followed by a comment.
+func yamlScalar(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		end := yamlQuoteEnd(s)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quote in %s", s)
+		} else if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("unexpected %q after %s", rest, s[:end+1])
+		} else if s[0] == '\'' {
+			return strings.Replace(s[1:end], "''", "'", -1), nil
+		}
+
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("bad escape in %s", s[:end+1])
+		}
+
+		return v, nil
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	switch {
+	case s == "" || s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return nil, nil
+	case strings.ContainsAny(s[:1], "[{&*!@`"):
+		return nil, fmt.Errorf("unsupported %s, quote it if it is a string", s)
+	default:
+		return s, nil
+	}
+}
+
+// decodeYAML sets v from a value of parseYAML, naming the fields of structs
+// by their json tags.
+func decodeYAML(y interface{}, v reflect.Value) error {
+	if y == nil {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return decodeYAML(y, v.Elem())
+	case reflect.Struct:
+		m, ok := y.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("yaml: %v is not a mapping", y)
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || name == "-" {
+				continue
+			} else if name == "" {
+				name = f.Name
+			}
+
+			if value, ok := m[name]; ok {
+				if err := decodeYAML(value, v.Field(i)); err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+	case reflect.Map:
+		m, ok := y.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("yaml: %v is not a mapping", y)
+		} else if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		for k, value := range m {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeYAML(value, e); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+		}
+	case reflect.Slice:
+		s, ok := y.([]interface{})
+		if !ok {
+			return fmt.Errorf("yaml: %v is not a sequence", y)
+		}
+
+		out := reflect.MakeSlice(v.Type(), len(s), len(s))
+		for i, value := range s {
+			if err := decodeYAML(value, out.Index(i)); err != nil {
+				return fmt.Errorf("%d: %v", i, err)
+			}
+		}
+
+		v.Set(out)
+	default:
+		s, ok := y.(string)
+		if !ok {
+			return fmt.Errorf("yaml: %v is not a scalar", y)
+		}
+
+		return decodeYAMLScalar(s, v)
+	}
+
+	return nil
+}
+
+func decodeYAMLScalar(s string, v reflect.Value) error {
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, 64); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 10, 64); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 64); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+		}
+	default:
+		return fmt.Errorf("yaml: can not set %s from %q", v.Type(), s)
+	}
+
+	if err != nil {
+		return fmt.Errorf("yaml: %q is not a %s", s, v.Type())
+	}
+
+	return nil
+}
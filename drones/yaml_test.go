@@ -0,0 +1,44 @@
+package drones
+
+import "testing"
+
+import (
+	"reflect"
+)
+
+func TestParseYAML(t *testing.T) {
+	for text, expect := range map[string]interface{}{
+		"":                        nil,
+		"# only a comment\n":      nil,
+		"---\na: 1\n":             map[string]interface{}{"a": "1"},
+		"a: b: c":                 map[string]interface{}{"a": "b: c"},
+		"a: http://x/?q=1#top":    map[string]interface{}{"a": "http://x/?q=1#top"},
+		"a: x # note":             map[string]interface{}{"a": "x"},
+		"a: ~\nb:":                map[string]interface{}{"a": nil, "b": nil},
+		`a: 'it''s # not'`:        map[string]interface{}{"a": "it's # not"},
+		`a: "tab\tand \"q\""`:     map[string]interface{}{"a": "tab\tand \"q\""},
+		`"a b": c`:                map[string]interface{}{"a b": "c"},
+		"a:\n- x\n- y\nb: z":      map[string]interface{}{"a": []interface{}{"x", "y"}, "b": "z"},
+		"- a: 1\n  b: 2\n- c":     []interface{}{map[string]interface{}{"a": "1", "b": "2"}, "c"},
+		"- - a\n  - b\n-\n  c":    []interface{}{[]interface{}{"a", "b"}, "c"},
+		"a:\n  b:\n    c: d":      map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "d"}}},
+		"a: |\n  x\n   y\n\nb: c": map[string]interface{}{"a": "x\n y\n", "b": "c"},
+		"a: |-\n  x\n  # y\n":     map[string]interface{}{"a": "x\n# y"},
+		"a: |+\n  x\n\n":          map[string]interface{}{"a": "x\n\n"},
+		"a: >\n  x\n  y\n\n  z\n": map[string]interface{}{"a": "x y\nz\n"},
+		"a: >-\n  x\n\n\n  y\nb:": map[string]interface{}{"a": "x\n\ny", "b": nil},
+	} {
+		v, err := parseYAML([]byte(text))
+		if err != nil {
+			t.Errorf("parseYAML(%q) failed: %v", text, err)
+		} else if !reflect.DeepEqual(v, expect) {
+			t.Errorf("parseYAML(%q) %#v != %#v", text, v, expect)
+		}
+	}
+
+	for _, text := range []string{"a: [1, 2]", "a: {b: c}", "a: &x 1", "a: 'open", "a: \"x\" y", "a: 1\na: 2", "a: 1\n b: 2", "  a: 1\nb: 2", "a: |2\n  x", "a:\n\t- x"} {
+		if _, err := parseYAML([]byte(text)); err == nil {
+			t.Errorf("parseYAML(%q) should fail", text)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package antpost
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"syscall"
+)
+
+// ErrorClass names a kind of failure. Drones may use classes of their own
+// by returning a ClassifiedError.
+type ErrorClass string
+
+const (
+	ErrorDNS      ErrorClass = "dns"
+	ErrorRefused  ErrorClass = "connection refused"
+	ErrorReset    ErrorClass = "connection reset"
+	ErrorClosed   ErrorClass = "connection closed"
+	ErrorTimeout  ErrorClass = "timeout"
+	ErrorTLS      ErrorClass = "tls"
+	ErrorCanceled ErrorClass = "canceled"
+	ErrorHTTP4xx  ErrorClass = "http 4xx"
+	ErrorHTTP5xx  ErrorClass = "http 5xx"
+	ErrorCheck    ErrorClass = "check"
+	ErrorOther    ErrorClass = "other"
+
+	// of failed iterations without an error
+	ErrorConnect        ErrorClass = "connect"
+	ErrorResponseBroken ErrorClass = "response broken"
+)
+
+// ClassifiedError is an error of a known class.
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func NewClassifiedError(class ErrorClass, err error) *ClassifiedError {
+	return &ClassifiedError{class, err}
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError tells the class of err, as set by a ClassifiedError it
+// wraps, or else by the network or TLS error it wraps.
+func ClassifyError(err error) ErrorClass {
+	var classified *ClassifiedError
+	var dns *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &classified):
+		return classified.Class
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.As(err, &dns):
+		return ErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ErrorReset
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case isTLSError(err):
+		return ErrorTLS
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClosed
+	default:
+		return ErrorOther
+	}
+}
+
+// isTLSError tells TLS errors by type: a bad record, a failed verify of
+// the certificates, or an alert, sent as a QUIC error or received as a
+// "remote error".
+func isTLSError(err error) bool {
+	var header tls.RecordHeaderError
+	var verify *tls.CertificateVerificationError
+	var alert tls.AlertError
+	var authority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var op *net.OpError
+	return errors.As(err, &header) || errors.As(err, &verify) || errors.As(err, &alert) ||
+		errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid) ||
+		errors.As(err, &op) && op.Op == "remote error"
+}
+
+// errorSamples is how many distinct messages ErrorReport keeps per class.
+const errorSamples = 3
+
+func (c *Context) errorReport() *ErrorReport {
+	classes := make(map[ErrorClass]*ErrorClassReport)
+	n := 0
+	for _, h := range c.history {
+		if h.result == ResultOK {
+			continue
+		}
+
+		var class ErrorClass
+		switch {
+		case h.err != nil:
+			class = ClassifyError(h.err)
+		case h.result == ResultConnectFail:
+			class = ErrorConnect
+		case h.result == ResultResponseBroken:
+			class = ErrorResponseBroken
+		case h.result == ResultCheckFail:
+			class = ErrorCheck
+		default:
+			class = ErrorOther
+		}
+
+		e, ok := classes[class]
+		if !ok {
+			e = &ErrorClassReport{Class: class}
+			classes[class] = e
+		}
+
+		n++
+		e.N++
+		if h.err != nil && len(e.Samples) < errorSamples {
+			sample := h.err.Error()
+			found := false
+			for _, s := range e.Samples {
+				found = found || s == sample
+			}
+
+			if !found {
+				e.Samples = append(e.Samples, sample)
+			}
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	r := &ErrorReport{n, make([]*ErrorClassReport, 0, len(classes))}
+	for _, e := range classes {
+		e.Percent = float32(e.N) * 100 / float32(n)
+		r.Classes = append(r.Classes, e)
+	}
+
+	sort.Sort(errorClassReports(r.Classes))
+	return r
+}
+
+type errorClassReports []*ErrorClassReport
+
+func (e errorClassReports) Len() int      { return len(e) }
+func (e errorClassReports) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e errorClassReports) Less(i, j int) bool {
+	return e[i].N > e[j].N || e[i].N == e[j].N && e[i].Class < e[j].Class
+}
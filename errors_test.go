@@ -0,0 +1,75 @@
+package antpost
+
+import "testing"
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		err   error
+		class ErrorClass
+	}{
+		{&net.DNSError{Err: "no such host", Name: "x"}, ErrorDNS},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ErrorRefused},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), ErrorReset},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), ErrorTimeout},
+		{fmt.Errorf("get: %w", context.Canceled), ErrorCanceled},
+		{&net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}, ErrorTLS},
+		{fmt.Errorf("get: %w", tls.AlertError(42)), ErrorTLS},
+		{fmt.Errorf("get: %w", &tls.CertificateVerificationError{Err: errors.New("expired")}), ErrorTLS},
+		{errors.New("tls: not a typed error"), ErrorOther},
+		{fmt.Errorf("get: %w", io.EOF), ErrorClosed},
+		{NewClassifiedError(ErrorHTTP5xx, errors.New("status 503")), ErrorHTTP5xx},
+		{errors.New("?"), ErrorOther},
+	} {
+		if class := ClassifyError(c.err); class != c.class {
+			t.Errorf("ClassifyError(%v) = %q, not %q", c.err, class, c.class)
+		}
+	}
+}
+
+func TestReportErrors(t *testing.T) {
+	c := NewContext()
+	for i, err := range []error{nil, io.EOF, io.EOF, syscall.ECONNREFUSED, nil} {
+		c.Start()
+		if err != nil {
+			c.Error(err)
+		}
+
+		if i == 0 {
+			c.End(ResultOK)
+		} else {
+			c.End(ResultConnectFail)
+		}
+	}
+
+	r := c.Report()
+	e := r.Errors
+	if e == nil || e.N != 4 || len(e.Classes) != 3 {
+		t.Errorf("Report().Errors: %v", e)
+		return
+	}
+
+	if e.Classes[0].Class != ErrorClosed || e.Classes[0].N != 2 || e.Classes[0].Percent != 50 || len(e.Classes[0].Samples) != 1 {
+		t.Errorf("Report().Errors closed: %v", e.Classes[0])
+	}
+
+	if e.Classes[1].Class != ErrorConnect || e.Classes[2].Class != ErrorRefused {
+		t.Errorf("Report().Errors order: %v, %v", e.Classes[1], e.Classes[2])
+	}
+
+	j, _ := r.MarshalJSON()
+	u := new(Report)
+	if err := u.UnmarshalJSON(j); err != nil || u.Errors == nil || fmt.Sprint(u.Errors.Classes[0]) != fmt.Sprint(e.Classes[0]) {
+		t.Errorf("Report.UnmarshalJSON() errors: %v, %v", err, u.Errors)
+	}
+}
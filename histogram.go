@@ -0,0 +1,271 @@
+package antpost
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultPrecision is the number of significant decimal digits histograms
+// keep for Stat.Duration and Stat.Ratio values.
+const DefaultPrecision = 3
+
+// histogram is a log-linear, HDR style histogram of float64 values: each
+// power of two is split into 10^digits buckets, so a value is known to
+// within 10^-digits of itself. Memory is bounded by the precision and the
+// range of values, not by how many are recorded, and two histograms merge
+// by adding counts.
+type histogram struct {
+	digits  int
+	buckets int64
+	counts  map[int64]int64
+
+	n          int64
+	min        float64
+	max        float64
+	sum        float64
+	sumSquares float64
+
+	// positive values only, for geometric and harmonic means
+	positive   int64
+	logSum     float64
+	inverseSum float64
+}
+
+// the float64 exponent is in (-1100, 1100), this keeps keys of positive
+// values above zero and those of negative values below it.
+const histogramExponentOffset = 1100
+
+func newHistogram(digits int) *histogram {
+	if digits < 1 {
+		digits = 1
+	} else if digits > 5 {
+		digits = 5
+	}
+
+	h := new(histogram)
+	h.digits = digits
+	h.buckets = int64(math.Pow10(digits))
+	h.counts = make(map[int64]int64)
+	return h
+}
+
+func (h *histogram) key(v float64) int64 {
+	if v == 0 {
+		return 0
+	}
+
+	frac, exp := math.Frexp(math.Abs(v))
+	sub := int64((frac - 0.5) * 2 * float64(h.buckets))
+	if sub >= h.buckets {
+		sub = h.buckets - 1
+	}
+
+	k := int64(exp+histogramExponentOffset)*h.buckets + sub
+	if v < 0 {
+		return -k
+	}
+
+	return k
+}
+
+// value is the middle of the bucket of key k.
+func (h *histogram) value(k int64) float64 {
+	if k == 0 {
+		return 0
+	}
+
+	sign := 1.0
+	if k < 0 {
+		sign, k = -1, -k
+	}
+
+	exp := int(k/h.buckets) - histogramExponentOffset
+	sub := k % h.buckets
+	frac := 0.5 + (float64(sub)+0.5)/float64(2*h.buckets)
+	return sign * math.Ldexp(frac, exp)
+}
+
+func (h *histogram) record(v float64) {
+	h.recordN(v, 1)
+}
+
+func (h *histogram) recordN(v float64, count int64) {
+	if count <= 0 {
+		return
+	}
+
+	h.counts[h.key(v)] += count
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+
+	if h.n == 0 || v > h.max {
+		h.max = v
+	}
+
+	c := float64(count)
+	h.n += count
+	h.sum += v * c
+	h.sumSquares += v * v * c
+	if v > 0 {
+		h.positive += count
+		h.logSum += math.Log(v) * c
+		h.inverseSum += c / v
+	}
+}
+
+func (h *histogram) combine(v *histogram) {
+	if v.n == 0 {
+		return
+	}
+
+	if h.digits == v.digits {
+		for k, c := range v.counts {
+			h.counts[k] += c
+		}
+	} else {
+		for k, c := range v.counts {
+			h.counts[h.key(v.value(k))] += c
+		}
+	}
+
+	if h.n == 0 || v.min < h.min {
+		h.min = v.min
+	}
+
+	if h.n == 0 || v.max > h.max {
+		h.max = v.max
+	}
+
+	h.n += v.n
+	h.sum += v.sum
+	h.sumSquares += v.sumSquares
+	h.positive += v.positive
+	h.logSum += v.logSum
+	h.inverseSum += v.inverseSum
+}
+
+func (h *histogram) mean() float64 {
+	if h.n <= 0 {
+		return 0
+	}
+
+	return h.sum / float64(h.n)
+}
+
+func (h *histogram) standardDeviation() float64 {
+	if h.n <= 0 {
+		return 0
+	}
+
+	avg := h.mean()
+	d := h.sumSquares/float64(h.n) - avg*avg
+	if d < 0 {
+		return 0
+	}
+
+	return math.Sqrt(d)
+}
+
+func (h *histogram) geometricMean() float64 {
+	if h.positive <= 0 {
+		return 0
+	}
+
+	return math.Exp(h.logSum / float64(h.positive))
+}
+
+func (h *histogram) quadraticMean() float64 {
+	if h.n <= 0 {
+		return 0
+	}
+
+	return math.Sqrt(h.sumSquares / float64(h.n))
+}
+
+func (h *histogram) harmonicMean() float64 {
+	if h.positive <= 0 {
+		return 0
+	}
+
+	return float64(h.positive) / h.inverseSum
+}
+
+// percentiles interpolates between the buckets of the closest ranks like
+// calcPercentile, for each q in [0, 1] of quantiles.
+func (h *histogram) percentiles(quantiles ...float64) []float64 {
+	r := make([]float64, len(quantiles))
+	if h.n <= 0 {
+		return r
+	}
+
+	keys := make([]int64, 0, len(h.counts))
+	for k, _ := range h.counts {
+		keys = append(keys, k)
+	}
+
+	sort.Sort(int64s(keys))
+	at := func(rank int64) float64 {
+		var seen int64 = 0
+		for _, k := range keys {
+			seen += h.counts[k]
+			if rank < seen {
+				return math.Max(h.min, math.Min(h.max, h.value(k)))
+			}
+		}
+
+		return h.max
+	}
+
+	for i, q := range quantiles {
+		rank := q * float64(h.n-1)
+		if rank <= 0 {
+			r[i] = h.min
+			continue
+		} else if rank >= float64(h.n-1) {
+			r[i] = h.max
+			continue
+		}
+
+		lo := math.Floor(rank)
+		a, b := at(int64(lo)), at(int64(lo)+1)
+		r[i] = a + (b-a)*(rank-lo)
+	}
+
+	return r
+}
+
+func (h *histogram) durationReport(quantiles []float64) *DurationReport {
+	if h.n <= 0 {
+		return &DurationReport{N: 0}
+	}
+
+	p := h.percentiles(append([]float64{0.05, 0.50, 0.90, 0.95, 0.99, 0.999, 0.9999}, quantiles...)...)
+	r := new(DurationReport)
+	r.N = int(h.n)
+	r.Avg = time.Duration(h.mean())
+	r.Min = time.Duration(h.min)
+	r.P05 = time.Duration(p[0])
+	r.P50 = time.Duration(p[1])
+	r.P90 = time.Duration(p[2])
+	r.P95 = time.Duration(p[3])
+	r.P99 = time.Duration(p[4])
+	r.P999 = time.Duration(p[5])
+	r.P9999 = time.Duration(p[6])
+	r.Max = time.Duration(h.max)
+	if len(quantiles) > 0 {
+		r.Percentiles = make([]*DurationPercentile, 0, len(quantiles))
+		for i, q := range quantiles {
+			r.Percentiles = append(r.Percentiles, &DurationPercentile{q, time.Duration(p[7+i])})
+		}
+	}
+
+	return r
+}
+
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
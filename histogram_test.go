@@ -0,0 +1,63 @@
+package antpost
+
+import "testing"
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+func TestHistogramKey(t *testing.T) {
+	h := newHistogram(3)
+	for _, v := range []float64{1e-9, 0.3, 1, 7, 1234.5678, 3.6e12, -0.3, -1234.5678} {
+		if r := h.value(h.key(v)); math.Abs(r-v) > math.Abs(v)*1e-3 {
+			t.Errorf("histogram.value(key(%v)) => %v", v, r)
+		}
+	}
+
+	if h.key(-2) >= h.key(-1) || h.key(-1) >= h.key(0) || h.key(0) >= h.key(1e-300) || h.key(1) >= h.key(1.01) {
+		t.Errorf("histogram.key() is not ordered by value")
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	values := make([]float64, 100000)
+	a, b := newHistogram(3), newHistogram(3)
+	for i := range values {
+		values[i] = rand.ExpFloat64() * 1e6
+		if i%2 == 0 {
+			a.record(values[i])
+		} else {
+			b.record(values[i])
+		}
+	}
+
+	a.combine(b)
+	sort.Float64s(values)
+	quantiles := []float64{0, 0.05, 0.5, 0.95, 0.99, 0.999, 1}
+	for i, p := range a.percentiles(quantiles...) {
+		v := calcPercentile(values, quantiles[i])
+		if math.Abs(p-v) > v*2e-3 {
+			t.Errorf("histogram percentile %v => %v != %v", quantiles[i], p, v)
+		}
+	}
+
+	if a.n != int64(len(values)) || math.Abs(a.mean()-calcMean(values)) > 1e-3 {
+		t.Errorf("histogram n %v, mean %v != %v", a.n, a.mean(), calcMean(values))
+	}
+
+	if len(a.counts) > 20000 {
+		t.Errorf("histogram keeps %d buckets", len(a.counts))
+	}
+}
+
+func TestHistogramPrecision(t *testing.T) {
+	a, b := newHistogram(1), newHistogram(3)
+	a.record(100)
+	b.record(123)
+	a.combine(b)
+	if a.n != 2 || len(a.counts) != 2 || a.max != 123 {
+		t.Errorf("histogram combine precision: n %d, %v", a.n, a.counts)
+	}
+}
@@ -0,0 +1,560 @@
+package antpost
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ReportSchemaVersion is the "version" of the JSON that Report marshals to.
+//
+// In version 1, keys are snake_case. Objects keyed by stat names are sorted
+// by name, every other object keeps the field order below. Durations are
+// integer nanoseconds, in keys ending with "_ns". "fraction" values are in
+// [0, 1], "percent" values in [0, 100] and "quantile" values in [0, 1].
+// Times of day are RFC 3339 strings with nanoseconds. Optional sections
+// are left out when the run has none.
+//
+//	{
+//	  "version": 1,
+//	  "canceled": false,
+//	  "abort": {"reason", "at", "elapsed_ns"},
+//	  "summary": {"n", "elapsed_ns", "rps", "ok", "connect_fail", "response_broken", "check_fail", "error_fraction", "bytes_sent", "bytes_received"},
+//	  "time", "ok_time", "connect_time", "handshake_time", "response_time": <duration>,
+//	  "arrivals": {"rate", "scheduled", "issued", "delayed", "dropped", "max_lag_ns"},
+//	  "stages": [{"name", "start", "time": <duration>, "ok_time": <duration>}],
+//	  "workers": [{"worker", "n", "ok", "time": <duration>}],
+//	  "errors": {"n", "classes": [{"class", "n", "percent", "samples": [message]}]},
+//	  "labels": [{"name", "summary", "time", "ok_time", "connect_time", "handshake_time", "response_time"}],
+//	  "timeline": {"bucket_ns", "buckets": [{"start_ns", "n", "rps", "ok", "connect_fail", "response_broken", "check_fail", "time": <duration>}]},
+//	  "stat": <stat>
+//	}
+//
+//	<duration>: {"n", "avg_ns", "min_ns", "p05_ns", "p50_ns", "p90_ns", "p95_ns", "p99_ns", "p999_ns", "p9999_ns", "max_ns",
+//	             "percentiles": [{"quantile", "value_ns"}]}
+//
+//	<stat>: {
+//	  "bools":     {name: {"n", "true", "true_fraction", "false", "false_fraction"}},
+//	  "durations": {name: <duration>},
+//	  "subs":      {name: <stat>},
+//	  "nominals":  {name: [{"name", "n", "percent"}]},
+//	  "ordinals":  {name: [{"name", "order", "n", "percent", "cumulative_n", "cumulative_percent", "down_cumulative_n", "down_cumulative_percent"}]},
+//	  "intervals": {name: {"interval", "mean", "standard_deviation", "items": [{"value", "step", "n", "percent",
+//	                "cumulative_n", "cumulative_percent", "down_cumulative_n", "down_cumulative_percent", "mean", "standard_deviation"}]}},
+//	  "ratios":    {name: {"n", "mean", "geometric_mean", "quadratic_mean", "harmonic_mean", "standard_deviation",
+//	                "min", "p05", "p25", "p50", "p75", "p90", "p95", "p99", "p999", "p9999", "max", "percentiles": [{"quantile", "value"}]}}
+//	}
+//
+// Fields may be added within a version. Renaming or removing one, or
+// changing its unit, bumps the version.
+const ReportSchemaVersion = 1
+
+type jsonReport struct {
+	Version       int           `json:"version"`
+	Canceled      bool          `json:"canceled"`
+	Abort         *jsonAbort    `json:"abort,omitempty"`
+	Summary       *jsonSummary  `json:"summary,omitempty"`
+	Time          *jsonDuration `json:"time,omitempty"`
+	OKTime        *jsonDuration `json:"ok_time,omitempty"`
+	ConnectTime   *jsonDuration `json:"connect_time,omitempty"`
+	HandshakeTime *jsonDuration `json:"handshake_time,omitempty"`
+	ResponseTime  *jsonDuration `json:"response_time,omitempty"`
+	Arrivals      *jsonArrivals `json:"arrivals,omitempty"`
+	Stages        []*jsonStage  `json:"stages,omitempty"`
+	Workers       []*jsonWorker `json:"workers,omitempty"`
+	Labels        []*jsonLabel  `json:"labels,omitempty"`
+	Errors        *jsonErrors   `json:"errors,omitempty"`
+	Timeline      *jsonTimeline `json:"timeline,omitempty"`
+	Stat          *jsonStat     `json:"stat,omitempty"`
+}
+
+type jsonAbort struct {
+	Reason    string    `json:"reason"`
+	At        time.Time `json:"at"`
+	ElapsedNs int64     `json:"elapsed_ns"`
+}
+
+type jsonSummary struct {
+	N              int     `json:"n"`
+	ElapsedNs      int64   `json:"elapsed_ns"`
+	RPS            float64 `json:"rps"`
+	OK             int     `json:"ok"`
+	ConnectFail    int     `json:"connect_fail"`
+	ResponseBroken int     `json:"response_broken"`
+	CheckFail      int     `json:"check_fail"`
+	ErrorFraction  float64 `json:"error_fraction"`
+	BytesSent      int64   `json:"bytes_sent"`
+	BytesReceived  int64   `json:"bytes_received"`
+}
+
+type jsonDurationPercentile struct {
+	Quantile float64 `json:"quantile"`
+	ValueNs  int64   `json:"value_ns"`
+}
+
+type jsonDuration struct {
+	N           int                       `json:"n"`
+	AvgNs       int64                     `json:"avg_ns"`
+	MinNs       int64                     `json:"min_ns"`
+	P05Ns       int64                     `json:"p05_ns"`
+	P50Ns       int64                     `json:"p50_ns"`
+	P90Ns       int64                     `json:"p90_ns"`
+	P95Ns       int64                     `json:"p95_ns"`
+	P99Ns       int64                     `json:"p99_ns"`
+	P999Ns      int64                     `json:"p999_ns"`
+	P9999Ns     int64                     `json:"p9999_ns"`
+	MaxNs       int64                     `json:"max_ns"`
+	Percentiles []*jsonDurationPercentile `json:"percentiles,omitempty"`
+}
+
+type jsonArrivals struct {
+	Rate      float64 `json:"rate"`
+	Scheduled int     `json:"scheduled"`
+	Issued    int     `json:"issued"`
+	Delayed   int     `json:"delayed"`
+	Dropped   int     `json:"dropped"`
+	MaxLagNs  int64   `json:"max_lag_ns"`
+}
+
+type jsonStage struct {
+	Name   string        `json:"name"`
+	Start  time.Time     `json:"start"`
+	Time   *jsonDuration `json:"time"`
+	OKTime *jsonDuration `json:"ok_time"`
+}
+
+type jsonWorker struct {
+	Worker int           `json:"worker"`
+	N      int           `json:"n"`
+	OK     int           `json:"ok"`
+	Time   *jsonDuration `json:"time"`
+}
+
+type jsonErrors struct {
+	N       int               `json:"n"`
+	Classes []*jsonErrorClass `json:"classes"`
+}
+
+type jsonErrorClass struct {
+	Class   string   `json:"class"`
+	N       int      `json:"n"`
+	Percent float64  `json:"percent"`
+	Samples []string `json:"samples,omitempty"`
+}
+
+type jsonLabel struct {
+	Name          string        `json:"name"`
+	Summary       *jsonSummary  `json:"summary"`
+	Time          *jsonDuration `json:"time"`
+	OKTime        *jsonDuration `json:"ok_time"`
+	ConnectTime   *jsonDuration `json:"connect_time"`
+	HandshakeTime *jsonDuration `json:"handshake_time,omitempty"`
+	ResponseTime  *jsonDuration `json:"response_time"`
+}
+
+type jsonTimeline struct {
+	BucketNs int64                 `json:"bucket_ns"`
+	Buckets  []*jsonTimelineBucket `json:"buckets"`
+}
+
+type jsonTimelineBucket struct {
+	StartNs        int64         `json:"start_ns"`
+	N              int           `json:"n"`
+	RPS            float64       `json:"rps"`
+	OK             int           `json:"ok"`
+	ConnectFail    int           `json:"connect_fail"`
+	ResponseBroken int           `json:"response_broken"`
+	CheckFail      int           `json:"check_fail"`
+	Time           *jsonDuration `json:"time"`
+}
+
+type jsonStat struct {
+	Bools     map[string]*jsonBool          `json:"bools"`
+	Durations map[string]*jsonDuration      `json:"durations"`
+	Subs      map[string]*jsonStat          `json:"subs"`
+	Nominals  map[string][]*jsonNominalItem `json:"nominals"`
+	Ordinals  map[string][]*jsonOrdinalRank `json:"ordinals"`
+	Intervals map[string]*jsonInterval      `json:"intervals"`
+	Ratios    map[string]*jsonRatio         `json:"ratios"`
+}
+
+type jsonBool struct {
+	N             int     `json:"n"`
+	True          int     `json:"true"`
+	TrueFraction  float64 `json:"true_fraction"`
+	False         int     `json:"false"`
+	FalseFraction float64 `json:"false_fraction"`
+}
+
+type jsonNominalItem struct {
+	Name    string  `json:"name"`
+	N       int     `json:"n"`
+	Percent float64 `json:"percent"`
+}
+
+type jsonOrdinalRank struct {
+	Name                  string  `json:"name"`
+	Order                 int     `json:"order"`
+	N                     int     `json:"n"`
+	Percent               float64 `json:"percent"`
+	CumulativeN           int     `json:"cumulative_n"`
+	CumulativePercent     float64 `json:"cumulative_percent"`
+	DownCumulativeN       int     `json:"down_cumulative_n"`
+	DownCumulativePercent float64 `json:"down_cumulative_percent"`
+}
+
+type jsonInterval struct {
+	Interval          float64             `json:"interval"`
+	Mean              float64             `json:"mean"`
+	StandardDeviation float64             `json:"standard_deviation"`
+	Items             []*jsonIntervalItem `json:"items"`
+}
+
+type jsonIntervalItem struct {
+	Value                 float64 `json:"value"`
+	Step                  int     `json:"step"`
+	N                     int     `json:"n"`
+	Percent               float64 `json:"percent"`
+	CumulativeN           int     `json:"cumulative_n"`
+	CumulativePercent     float64 `json:"cumulative_percent"`
+	DownCumulativeN       int     `json:"down_cumulative_n"`
+	DownCumulativePercent float64 `json:"down_cumulative_percent"`
+	Mean                  float64 `json:"mean"`
+	StandardDeviation     float64 `json:"standard_deviation"`
+}
+
+type jsonRatioPercentile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type jsonRatio struct {
+	N                 int                    `json:"n"`
+	Mean              float64                `json:"mean"`
+	GeometricMean     float64                `json:"geometric_mean"`
+	QuadraticMean     float64                `json:"quadratic_mean"`
+	HarmonicMean      float64                `json:"harmonic_mean"`
+	StandardDeviation float64                `json:"standard_deviation"`
+	Min               float64                `json:"min"`
+	P05               float64                `json:"p05"`
+	P25               float64                `json:"p25"`
+	P50               float64                `json:"p50"`
+	P75               float64                `json:"p75"`
+	P90               float64                `json:"p90"`
+	P95               float64                `json:"p95"`
+	P99               float64                `json:"p99"`
+	P999              float64                `json:"p999"`
+	P9999             float64                `json:"p9999"`
+	Max               float64                `json:"max"`
+	Percentiles       []*jsonRatioPercentile `json:"percentiles,omitempty"`
+}
+
+// MarshalJSON encodes r in the schema of ReportSchemaVersion.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	j := new(jsonReport)
+	j.Version = ReportSchemaVersion
+	j.Canceled = r.Canceled
+	if a := r.Abort; a != nil {
+		j.Abort = &jsonAbort{a.Reason, a.At, int64(a.Elapsed)}
+	}
+
+	j.Summary = toJSONSummary(r.Summary)
+	j.Time = toJSONDuration(r.Time)
+	j.OKTime = toJSONDuration(r.OKTime)
+	j.ConnectTime = toJSONDuration(r.ConnectTime)
+	j.HandshakeTime = toJSONDuration(r.HandshakeTime)
+	j.ResponseTime = toJSONDuration(r.ResponseTime)
+	if a := r.Arrivals; a != nil {
+		j.Arrivals = &jsonArrivals{jsonFloat(a.Rate), a.Scheduled, a.Issued, a.Delayed, a.Dropped, int64(a.MaxLag)}
+	}
+
+	for _, s := range r.Stages {
+		j.Stages = append(j.Stages, &jsonStage{s.Name, s.Start, toJSONDuration(s.Time), toJSONDuration(s.OKTime)})
+	}
+
+	for _, w := range r.Workers {
+		j.Workers = append(j.Workers, &jsonWorker{w.Worker, w.N, w.OK, toJSONDuration(w.Time)})
+	}
+
+	if e := r.Errors; e != nil {
+		j.Errors = &jsonErrors{e.N, make([]*jsonErrorClass, 0, len(e.Classes))}
+		for _, c := range e.Classes {
+			j.Errors.Classes = append(j.Errors.Classes, &jsonErrorClass{string(c.Class), c.N, jsonFloat32(c.Percent), c.Samples})
+		}
+	}
+
+	for _, l := range r.Labels {
+		j.Labels = append(j.Labels, &jsonLabel{l.Name, toJSONSummary(l.Summary), toJSONDuration(l.Time), toJSONDuration(l.OKTime), toJSONDuration(l.ConnectTime), toJSONDuration(l.HandshakeTime), toJSONDuration(l.ResponseTime)})
+	}
+
+	if t := r.Timeline; t != nil {
+		j.Timeline = &jsonTimeline{int64(t.Bucket), make([]*jsonTimelineBucket, 0, len(t.Buckets))}
+		for _, b := range t.Buckets {
+			j.Timeline.Buckets = append(j.Timeline.Buckets, &jsonTimelineBucket{int64(b.Start), b.N, jsonFloat(b.RPS), b.OK, b.ConnectFail, b.ResponseBroken, b.CheckFail, toJSONDuration(b.Time)})
+		}
+	}
+
+	j.Stat = toJSONStat(r.Stat)
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes what MarshalJSON encodes, of any schema version
+// up to ReportSchemaVersion.
+func (r *Report) UnmarshalJSON(data []byte) error {
+	j := new(jsonReport)
+	if err := json.Unmarshal(data, j); err != nil {
+		return err
+	}
+
+	if j.Version <= 0 || j.Version > ReportSchemaVersion {
+		return fmt.Errorf("unsupported report schema version %d", j.Version)
+	}
+
+	*r = Report{}
+	r.Canceled = j.Canceled
+	if a := j.Abort; a != nil {
+		r.Abort = &AbortReport{a.Reason, a.At, time.Duration(a.ElapsedNs)}
+	}
+
+	r.Summary = fromJSONSummary(j.Summary)
+	r.Time = fromJSONDuration(j.Time)
+	r.OKTime = fromJSONDuration(j.OKTime)
+	r.ConnectTime = fromJSONDuration(j.ConnectTime)
+	r.HandshakeTime = fromJSONDuration(j.HandshakeTime)
+	r.ResponseTime = fromJSONDuration(j.ResponseTime)
+	if a := j.Arrivals; a != nil {
+		r.Arrivals = &ArrivalReport{a.Rate, a.Scheduled, a.Issued, a.Delayed, a.Dropped, time.Duration(a.MaxLagNs)}
+	}
+
+	for _, s := range j.Stages {
+		r.Stages = append(r.Stages, &StageReport{s.Name, s.Start, fromJSONDuration(s.Time), fromJSONDuration(s.OKTime)})
+	}
+
+	for _, w := range j.Workers {
+		r.Workers = append(r.Workers, &WorkerReport{w.Worker, w.N, w.OK, fromJSONDuration(w.Time)})
+	}
+
+	if e := j.Errors; e != nil {
+		r.Errors = &ErrorReport{e.N, make([]*ErrorClassReport, 0, len(e.Classes))}
+		for _, c := range e.Classes {
+			r.Errors.Classes = append(r.Errors.Classes, &ErrorClassReport{ErrorClass(c.Class), c.N, float32(c.Percent), c.Samples})
+		}
+	}
+
+	for _, l := range j.Labels {
+		r.Labels = append(r.Labels, &LabelReport{l.Name, fromJSONSummary(l.Summary), fromJSONDuration(l.Time), fromJSONDuration(l.OKTime), fromJSONDuration(l.ConnectTime), fromJSONDuration(l.HandshakeTime), fromJSONDuration(l.ResponseTime)})
+	}
+
+	if t := j.Timeline; t != nil {
+		r.Timeline = &TimelineReport{time.Duration(t.BucketNs), make([]*TimelineBucket, 0, len(t.Buckets))}
+		for _, b := range t.Buckets {
+			r.Timeline.Buckets = append(r.Timeline.Buckets, &TimelineBucket{time.Duration(b.StartNs), b.N, b.RPS, b.OK, b.ConnectFail, b.ResponseBroken, b.CheckFail, fromJSONDuration(b.Time)})
+		}
+	}
+
+	r.Stat = fromJSONStat(j.Stat)
+	return nil
+}
+
+// jsonFloat keeps NaN and infinities, which JSON can't hold, out of reports.
+func jsonFloat(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+
+	return f
+}
+
+// jsonFloat32 keeps the shortest decimal of a float32 percent, rather than
+// the float64 it widens to.
+func jsonFloat32(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return jsonFloat(v)
+}
+
+func toJSONSummary(s *SummaryReport) *jsonSummary {
+	if s == nil {
+		return nil
+	}
+
+	return &jsonSummary{s.N, int64(s.Elapsed), jsonFloat(s.RPS), s.OK, s.ConnectFail, s.ResponseBroken, s.CheckFail, jsonFloat(s.ErrorRate), s.BytesSent, s.BytesReceived}
+}
+
+func fromJSONSummary(s *jsonSummary) *SummaryReport {
+	if s == nil {
+		return nil
+	}
+
+	return &SummaryReport{s.N, time.Duration(s.ElapsedNs), s.RPS, s.OK, s.ConnectFail, s.ResponseBroken, s.CheckFail, s.ErrorFraction, s.BytesSent, s.BytesReceived}
+}
+
+func toJSONDuration(d *DurationReport) *jsonDuration {
+	if d == nil {
+		return nil
+	}
+
+	j := &jsonDuration{d.N, int64(d.Avg), int64(d.Min), int64(d.P05), int64(d.P50), int64(d.P90), int64(d.P95), int64(d.P99), int64(d.P999), int64(d.P9999), int64(d.Max), nil}
+	for _, p := range d.Percentiles {
+		j.Percentiles = append(j.Percentiles, &jsonDurationPercentile{p.Quantile, int64(p.Value)})
+	}
+
+	return j
+}
+
+func fromJSONDuration(j *jsonDuration) *DurationReport {
+	if j == nil {
+		return nil
+	}
+
+	d := new(DurationReport)
+	d.N = j.N
+	d.Avg = time.Duration(j.AvgNs)
+	d.Min = time.Duration(j.MinNs)
+	d.P05 = time.Duration(j.P05Ns)
+	d.P50 = time.Duration(j.P50Ns)
+	d.P90 = time.Duration(j.P90Ns)
+	d.P95 = time.Duration(j.P95Ns)
+	d.P99 = time.Duration(j.P99Ns)
+	d.P999 = time.Duration(j.P999Ns)
+	d.P9999 = time.Duration(j.P9999Ns)
+	d.Max = time.Duration(j.MaxNs)
+	for _, p := range j.Percentiles {
+		d.Percentiles = append(d.Percentiles, &DurationPercentile{p.Quantile, time.Duration(p.ValueNs)})
+	}
+
+	return d
+}
+
+func toJSONStat(s *StatReport) *jsonStat {
+	if s == nil {
+		return nil
+	}
+
+	j := new(jsonStat)
+	j.Bools = make(map[string]*jsonBool)
+	j.Durations = make(map[string]*jsonDuration)
+	j.Subs = make(map[string]*jsonStat)
+	j.Nominals = make(map[string][]*jsonNominalItem)
+	j.Ordinals = make(map[string][]*jsonOrdinalRank)
+	j.Intervals = make(map[string]*jsonInterval)
+	j.Ratios = make(map[string]*jsonRatio)
+
+	for n, b := range s.Bools {
+		j.Bools[n] = &jsonBool{b.N, b.True, jsonFloat32(b.TruePercent), b.False, jsonFloat32(b.FalsePercent)}
+	}
+
+	for n, d := range s.Durations {
+		j.Durations[n] = toJSONDuration(d)
+	}
+
+	for n, sub := range s.Subs {
+		j.Subs[n] = toJSONStat(sub)
+	}
+
+	for n, v := range s.Nominals {
+		items := make([]*jsonNominalItem, 0, len(v.Items))
+		for _, item := range v.Items {
+			items = append(items, &jsonNominalItem{item.Name, item.N, jsonFloat32(item.Percent)})
+		}
+
+		j.Nominals[n] = items
+	}
+
+	for n, v := range s.Ordinals {
+		ranks := make([]*jsonOrdinalRank, 0, len(v.Ranks))
+		for _, r := range v.Ranks {
+			ranks = append(ranks, &jsonOrdinalRank{r.Name, r.Order, r.N, jsonFloat32(r.Percent), r.CumulativeN, jsonFloat32(r.CumulativePercent), r.DownCumulativeN, jsonFloat32(r.DownCumulativePercent)})
+		}
+
+		j.Ordinals[n] = ranks
+	}
+
+	for n, v := range s.Intervals {
+		i := &jsonInterval{jsonFloat(v.Interval), jsonFloat(v.Mean), jsonFloat(v.StandardDeviation), make([]*jsonIntervalItem, 0, len(v.Items))}
+		for _, item := range v.Items {
+			i.Items = append(i.Items, &jsonIntervalItem{jsonFloat(item.Value), item.Step, item.N, jsonFloat32(item.Percent), item.CumulativeN, jsonFloat32(item.CumulativePercent), item.DownCumulativeN, jsonFloat32(item.DownCumulativePercent), jsonFloat(item.Mean), jsonFloat(item.StandardDeviation)})
+		}
+
+		j.Intervals[n] = i
+	}
+
+	for n, v := range s.Ratios {
+		r := &jsonRatio{v.N, jsonFloat(v.Mean), jsonFloat(v.GeometricMean), jsonFloat(v.QuadraticMean), jsonFloat(v.HarmonicMean), jsonFloat(v.StandardDeviation),
+			jsonFloat(v.Min), jsonFloat(v.P05), jsonFloat(v.P25), jsonFloat(v.P50), jsonFloat(v.P75), jsonFloat(v.P90), jsonFloat(v.P95), jsonFloat(v.P99), jsonFloat(v.P999), jsonFloat(v.P9999), jsonFloat(v.Max), nil}
+		for _, p := range v.Percentiles {
+			r.Percentiles = append(r.Percentiles, &jsonRatioPercentile{p.Quantile, jsonFloat(p.Value)})
+		}
+
+		j.Ratios[n] = r
+	}
+
+	return j
+}
+
+func fromJSONStat(j *jsonStat) *StatReport {
+	if j == nil {
+		return nil
+	}
+
+	s := new(StatReport)
+	s.Bools = make(map[string]*BoolReport)
+	s.Durations = make(map[string]*DurationReport)
+	s.Subs = make(map[string]*StatReport)
+	s.Nominals = make(map[string]*NominalReport)
+	s.Ordinals = make(map[string]*OrdinalReport)
+	s.Intervals = make(map[string]*IntervalReport)
+	s.Ratios = make(map[string]*RatioReport)
+
+	for n, b := range j.Bools {
+		s.Bools[n] = &BoolReport{b.N, b.True, float32(b.TrueFraction), b.False, float32(b.FalseFraction)}
+	}
+
+	for n, d := range j.Durations {
+		s.Durations[n] = fromJSONDuration(d)
+	}
+
+	for n, sub := range j.Subs {
+		s.Subs[n] = fromJSONStat(sub)
+	}
+
+	for n, items := range j.Nominals {
+		v := &NominalReport{make([]*NominalReportItem, 0, len(items))}
+		for _, item := range items {
+			v.Items = append(v.Items, &NominalReportItem{item.Name, item.N, float32(item.Percent)})
+		}
+
+		s.Nominals[n] = v
+	}
+
+	for n, ranks := range j.Ordinals {
+		v := &OrdinalReport{make([]*OrdinalReportRank, 0, len(ranks))}
+		for _, r := range ranks {
+			v.Ranks = append(v.Ranks, &OrdinalReportRank{r.Name, r.Order, r.N, float32(r.Percent), r.CumulativeN, float32(r.CumulativePercent), r.DownCumulativeN, float32(r.DownCumulativePercent)})
+		}
+
+		s.Ordinals[n] = v
+	}
+
+	for n, i := range j.Intervals {
+		v := &IntervalReport{i.Interval, make([]*IntervalReportItem, 0, len(i.Items)), i.Mean, i.StandardDeviation}
+		for _, item := range i.Items {
+			v.Items = append(v.Items, &IntervalReportItem{item.Value, item.Step, item.N, float32(item.Percent), item.CumulativeN, float32(item.CumulativePercent), item.DownCumulativeN, float32(item.DownCumulativePercent), item.Mean, item.StandardDeviation})
+		}
+
+		s.Intervals[n] = v
+	}
+
+	for n, r := range j.Ratios {
+		v := &RatioReport{N: r.N, Mean: r.Mean, GeometricMean: r.GeometricMean, QuadraticMean: r.QuadraticMean, HarmonicMean: r.HarmonicMean, StandardDeviation: r.StandardDeviation,
+			P05: r.P05, P25: r.P25, P50: r.P50, P75: r.P75, P95: r.P95, Min: r.Min, P90: r.P90, P99: r.P99, P999: r.P999, P9999: r.P9999, Max: r.Max}
+		for _, p := range r.Percentiles {
+			v.Percentiles = append(v.Percentiles, &RatioPercentile{p.Quantile, p.Value})
+		}
+
+		s.Ratios[n] = v
+	}
+
+	return s
+}
@@ -0,0 +1,60 @@
+package antpost
+
+import "testing"
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func TestReportJSON(t *testing.T) {
+	c := NewContext()
+	c.SetPercentiles(0.75)
+	begin := time.Now()
+	for i := 1; i <= 10; i++ {
+		c.history = append(c.history, &droneContext{worker: 1, stage: "s", step: StepResponsed, start: begin, connected: begin, responsed: begin.Add(time.Duration(i) * time.Millisecond), end: begin.Add(time.Duration(i) * time.Millisecond)})
+		c.stat.Bool("b", i%3 == 0)
+		c.stat.Sub("login").Duration("auth", time.Duration(i)*time.Millisecond)
+		c.stat.Nominal("n", "x")
+		c.stat.Ordinal("o", NewOrdinalGen("lo", "hi").Ord("hi"))
+		c.stat.Interval("i", float64(i))
+		c.stat.Ratio("r", float64(i))
+	}
+
+	r := c.Report()
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Errorf("json.Marshal(Report) failed: %v", err)
+		return
+	}
+
+	if !bytes.HasPrefix(data, []byte(`{"version":1,`)) || !strings.Contains(string(data), `"auth":{"n":10,"avg_ns":5500000,`) {
+		t.Errorf("json.Marshal(Report) => %s", data)
+	}
+
+	again, _ := json.Marshal(r)
+	if !bytes.Equal(data, again) {
+		t.Errorf("json.Marshal(Report) is not deterministic")
+	}
+
+	back := new(Report)
+	if err := json.Unmarshal(data, back); err != nil {
+		t.Errorf("json.Unmarshal(Report) failed: %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(back.Time, r.Time) || !reflect.DeepEqual(back.Stat.Subs["login"], r.Stat.Subs["login"]) || !reflect.DeepEqual(back.Stat.Ratios, r.Stat.Ratios) {
+		t.Errorf("json.Unmarshal(Report) => %v", back)
+	}
+
+	if back.Stat.Bools["b"].True != 3 || back.Stat.Nominals["n"].Items[0].Percent != 100 {
+		t.Errorf("json.Unmarshal(Report) stats => %v", back.Stat)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":99}`), back); err == nil {
+		t.Errorf("json.Unmarshal(Report) should refuse unknown versions")
+	}
+}
@@ -79,3 +79,23 @@ func calcHarmonicMean(values []float64) float64 {
 		return 0
 	}
 }
+
+// calcPercentile interpolates linearly between the closest ranks of sorted
+// values, q in [0, 1].
+func calcPercentile(sorted []float64, q float64) float64 {
+	n := len(sorted)
+	if n <= 0 {
+		return 0
+	}
+
+	rank := q * float64(n-1)
+	if rank <= 0 {
+		return sorted[0]
+	} else if rank >= float64(n-1) {
+		return sorted[n-1]
+	}
+
+	lo := math.Floor(rank)
+	i := int(lo)
+	return sorted[i] + (sorted[i+1]-sorted[i])*(rank-lo)
+}
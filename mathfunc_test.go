@@ -45,3 +45,16 @@ func TestHarmonicMean(t *testing.T) {
 		t.Errorf("calcHarmonicMean(%v) => %v != %v", values, h, hm)
 	}
 }
+
+func TestPercentile(t *testing.T) {
+	values := []float64{10, 20, 30, 40, 50}
+	for q, p := range map[float64]float64{0: 10, 0.5: 30, 0.9: 46, 0.95: 48, 1: 50} {
+		if v := calcPercentile(values, q); !isFloat64Approach(v, p, p) {
+			t.Errorf("calcPercentile(%v, %v) => %v != %v", values, q, v, p)
+		}
+	}
+
+	if v := calcPercentile([]float64{7}, 0.99); v != 7 {
+		t.Errorf("calcPercentile([7], 0.99) => %v != 7", v)
+	}
+}
@@ -0,0 +1,238 @@
+package antpost
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MetricKind int
+
+const (
+	MetricCount    MetricKind = iota
+	MetricDuration MetricKind = iota // in nanoseconds
+	MetricRate     MetricKind = iota // per second
+	MetricFraction MetricKind = iota // in [0, 1]
+	MetricPercent  MetricKind = iota // in [0, 100]
+	MetricValue    MetricKind = iota // as recorded by Stat.Ratio or Stat.Interval
+)
+
+type metricDirection int
+
+const (
+	higherIsWorse metricDirection = iota
+	lowerIsWorse  metricDirection = iota
+	changeIsWorse metricDirection = iota
+	informational metricDirection = iota
+)
+
+// Metric is one number of a Report, named by its path of field and stat
+// names, e.g. "Time.P95" or "Subs.login.Durations.auth.P95".
+type Metric struct {
+	Path  string
+	Kind  MetricKind
+	Value float64
+
+	worse metricDirection
+}
+
+func (m *Metric) String() string {
+	return m.Path + " = " + m.Kind.format(m.Value)
+}
+
+func (k MetricKind) format(v float64) string {
+	switch k {
+	case MetricCount:
+		return fmt.Sprintf("%.0f", v)
+	case MetricDuration:
+		return time.Duration(v).String()
+	case MetricRate:
+		return fmt.Sprintf("%.2f/s", v)
+	case MetricFraction:
+		return fmt.Sprintf("%.2f%%", v*100)
+	case MetricPercent:
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return fmt.Sprintf("%g", v)
+	}
+}
+
+// Metrics lists every number of r by path, sorted by path. Labels go under
+// "Labels.<name>." and error classes under "Errors.<class>.", while stages,
+// workers and the timeline are left out.
+func (r *Report) Metrics() []*Metric {
+	m := make([]*Metric, 0)
+	add := func(path string, kind MetricKind, worse metricDirection, v float64) {
+		m = append(m, &Metric{path, kind, v, worse})
+	}
+
+	phaseMetrics(r.Summary, r.Time, r.OKTime, r.ConnectTime, r.HandshakeTime, r.ResponseTime, "", add)
+	if a := r.Arrivals; a != nil {
+		add("Arrivals.Scheduled", MetricCount, informational, float64(a.Scheduled))
+		add("Arrivals.Issued", MetricCount, informational, float64(a.Issued))
+		add("Arrivals.Delayed", MetricCount, higherIsWorse, float64(a.Delayed))
+		add("Arrivals.Dropped", MetricCount, higherIsWorse, float64(a.Dropped))
+		add("Arrivals.MaxLag", MetricDuration, higherIsWorse, float64(a.MaxLag))
+	}
+
+	if e := r.Errors; e != nil {
+		for _, c := range e.Classes {
+			add("Errors."+string(c.Class)+".N", MetricCount, higherIsWorse, float64(c.N))
+			add("Errors."+string(c.Class)+".Percent", MetricPercent, changeIsWorse, float64(c.Percent))
+		}
+	}
+
+	for _, l := range r.Labels {
+		phaseMetrics(l.Summary, l.Time, l.OKTime, l.ConnectTime, l.HandshakeTime, l.ResponseTime, "Labels."+l.Name+".", add)
+	}
+
+	if r.Stat != nil {
+		statMetrics(r.Stat, "", add)
+	}
+
+	sort.Sort(metrics(m))
+	return m
+}
+
+// Metric finds the metric of r at path.
+func (r *Report) Metric(path string) (*Metric, bool) {
+	for _, m := range r.Metrics() {
+		if m.Path == path {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+type metricAdder func(path string, kind MetricKind, worse metricDirection, v float64)
+
+func phaseMetrics(s *SummaryReport, d, ok, connect, handshake, response *DurationReport, prefix string, add metricAdder) {
+	if s != nil {
+		add(prefix+"Summary.N", MetricCount, informational, float64(s.N))
+		add(prefix+"Summary.Elapsed", MetricDuration, informational, float64(s.Elapsed))
+		add(prefix+"Summary.RPS", MetricRate, lowerIsWorse, s.RPS)
+		add(prefix+"Summary.OK", MetricCount, informational, float64(s.OK))
+		add(prefix+"Summary.ConnectFail", MetricCount, higherIsWorse, float64(s.ConnectFail))
+		add(prefix+"Summary.ResponseBroken", MetricCount, higherIsWorse, float64(s.ResponseBroken))
+		add(prefix+"Summary.CheckFail", MetricCount, higherIsWorse, float64(s.CheckFail))
+		add(prefix+"Summary.ErrorRate", MetricFraction, higherIsWorse, s.ErrorRate)
+		add(prefix+"Summary.BytesSent", MetricCount, informational, float64(s.BytesSent))
+		add(prefix+"Summary.BytesReceived", MetricCount, informational, float64(s.BytesReceived))
+	}
+
+	durationMetrics(d, prefix+"Time.", add)
+	durationMetrics(ok, prefix+"OKTime.", add)
+	durationMetrics(connect, prefix+"ConnectTime.", add)
+	durationMetrics(handshake, prefix+"HandshakeTime.", add)
+	durationMetrics(response, prefix+"ResponseTime.", add)
+}
+
+func durationMetrics(d *DurationReport, prefix string, add metricAdder) {
+	if d == nil {
+		return
+	}
+
+	// Min, Max and P9999 hang on a sample or a few, too noisy to judge.
+	add(prefix+"N", MetricCount, informational, float64(d.N))
+	for _, v := range []struct {
+		name  string
+		value time.Duration
+		worse metricDirection
+	}{
+		{"Avg", d.Avg, higherIsWorse}, {"Min", d.Min, informational}, {"P05", d.P05, higherIsWorse},
+		{"P50", d.P50, higherIsWorse}, {"P90", d.P90, higherIsWorse}, {"P95", d.P95, higherIsWorse},
+		{"P99", d.P99, higherIsWorse}, {"P999", d.P999, higherIsWorse}, {"P9999", d.P9999, informational},
+		{"Max", d.Max, informational},
+	} {
+		add(prefix+v.name, MetricDuration, v.worse, float64(v.value))
+	}
+
+	for _, p := range d.Percentiles {
+		name := quantileName(p.Quantile)
+		if !standardQuantiles[name] {
+			add(prefix+name, MetricDuration, higherIsWorse, float64(p.Value))
+		}
+	}
+}
+
+var standardQuantiles = map[string]bool{"P05": true, "P50": true, "P90": true, "P95": true, "P99": true, "P999": true, "P9999": true}
+
+// quantileName names quantile q like the fields of DurationReport, 0.05 as
+// "P05" and 0.999 as "P999".
+func quantileName(q float64) string {
+	s := strings.Replace(strconv.FormatFloat(math.Round(q*1e6)/1e4, 'f', -1, 64), ".", "", 1)
+	if q < 0.1 {
+		s = "0" + s
+	}
+
+	return "P" + s
+}
+
+func statMetrics(s *StatReport, prefix string, add metricAdder) {
+	for n, b := range s.Bools {
+		p := prefix + "Bools." + n + "."
+		add(p+"N", MetricCount, informational, float64(b.N))
+		add(p+"True", MetricCount, informational, float64(b.True))
+		add(p+"TruePercent", MetricFraction, changeIsWorse, float64(b.TruePercent))
+	}
+
+	for n, d := range s.Durations {
+		durationMetrics(d, prefix+"Durations."+n+".", add)
+	}
+
+	for n, sub := range s.Subs {
+		statMetrics(sub, prefix+"Subs."+n+".", add)
+	}
+
+	for n, v := range s.Nominals {
+		for _, item := range v.Items {
+			p := prefix + "Nominals." + n + "." + item.Name + "."
+			add(p+"N", MetricCount, informational, float64(item.N))
+			add(p+"Percent", MetricPercent, changeIsWorse, float64(item.Percent))
+		}
+	}
+
+	for n, v := range s.Ordinals {
+		for _, rank := range v.Ranks {
+			p := prefix + "Ordinals." + n + "." + rank.Name + "."
+			add(p+"N", MetricCount, informational, float64(rank.N))
+			add(p+"Percent", MetricPercent, changeIsWorse, float64(rank.Percent))
+			add(p+"CumulativePercent", MetricPercent, changeIsWorse, float64(rank.CumulativePercent))
+		}
+	}
+
+	for n, v := range s.Intervals {
+		p := prefix + "Intervals." + n + "."
+		add(p+"Mean", MetricValue, changeIsWorse, v.Mean)
+		add(p+"StandardDeviation", MetricValue, changeIsWorse, v.StandardDeviation)
+	}
+
+	for n, v := range s.Ratios {
+		p := prefix + "Ratios." + n + "."
+		add(p+"N", MetricCount, informational, float64(v.N))
+		for _, f := range []struct {
+			name  string
+			value float64
+		}{{"Mean", v.Mean}, {"GeometricMean", v.GeometricMean}, {"QuadraticMean", v.QuadraticMean}, {"HarmonicMean", v.HarmonicMean}, {"StandardDeviation", v.StandardDeviation},
+			{"Min", v.Min}, {"P05", v.P05}, {"P25", v.P25}, {"P50", v.P50}, {"P75", v.P75}, {"P90", v.P90}, {"P95", v.P95}, {"P99", v.P99}, {"P999", v.P999}, {"P9999", v.P9999}, {"Max", v.Max}} {
+			add(p+f.name, MetricValue, changeIsWorse, f.value)
+		}
+
+		for _, q := range v.Percentiles {
+			name := quantileName(q.Quantile)
+			if !standardQuantiles[name] && name != "P25" && name != "P75" {
+				add(p+name, MetricValue, changeIsWorse, q.Value)
+			}
+		}
+	}
+}
+
+type metrics []*Metric
+
+func (m metrics) Len() int           { return len(m) }
+func (m metrics) Less(i, j int) bool { return m[i].Path < m[j].Path }
+func (m metrics) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
@@ -0,0 +1,168 @@
+package antpost
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// LoadProfile describes how load changes over a run. Target is the number
+// of goroutines for RunProfile, or arrivals per second for RunRateProfile.
+type LoadProfile interface {
+	Target(t time.Duration) float64
+	Stage(t time.Duration) string
+	Duration() time.Duration
+}
+
+// NewLinearRamp goes linearly from `from' to `to' over d.
+func NewLinearRamp(from, to float64, d time.Duration) LoadProfile {
+	return &linearRamp{from, to, d}
+}
+
+// NewSteps holds each level for stepDuration, one after another. It is nil
+// unless stepDuration is positive.
+func NewSteps(stepDuration time.Duration, levels ...float64) LoadProfile {
+	if stepDuration <= 0 {
+		return nil
+	}
+
+	return &steps{stepDuration, levels}
+}
+
+// NewStaircase starts at `from' and adds `step' every stepDuration, for
+// count stairs. It is nil unless stepDuration is positive.
+func NewStaircase(from, step float64, count int, stepDuration time.Duration) LoadProfile {
+	levels := make([]float64, count)
+	for i := range levels {
+		levels[i] = from + step*float64(i)
+	}
+
+	return NewSteps(stepDuration, levels...)
+}
+
+// NewSpike holds base for d, except for a jump to peak that lasts spike,
+// starting at `at'.
+func NewSpike(base, peak float64, d, at, spike time.Duration) LoadProfile {
+	return &spikeProfile{base, peak, d, at, spike}
+}
+
+// NewSine oscillates around mean by amplitude, once per period. It is nil
+// unless period is positive.
+func NewSine(mean, amplitude float64, period, d time.Duration) LoadProfile {
+	if period <= 0 {
+		return nil
+	}
+
+	return &sine{mean, amplitude, period, d}
+}
+
+type linearRamp struct {
+	from float64
+	to   float64
+	d    time.Duration
+}
+
+func (r *linearRamp) Target(t time.Duration) float64 {
+	if t >= r.d {
+		return r.to
+	}
+
+	return r.from + (r.to-r.from)*float64(t)/float64(r.d)
+}
+
+func (r *linearRamp) Stage(t time.Duration) string {
+	return "ramp"
+}
+
+func (r *linearRamp) Duration() time.Duration {
+	return r.d
+}
+
+type steps struct {
+	d      time.Duration
+	levels []float64
+}
+
+func (s *steps) step(t time.Duration) int {
+	i := int(t / s.d)
+	if i >= len(s.levels) {
+		i = len(s.levels) - 1
+	}
+
+	return i
+}
+
+func (s *steps) Target(t time.Duration) float64 {
+	if len(s.levels) == 0 {
+		return 0
+	}
+
+	return s.levels[s.step(t)]
+}
+
+func (s *steps) Stage(t time.Duration) string {
+	if len(s.levels) == 0 {
+		return ""
+	}
+
+	i := s.step(t)
+	return fmt.Sprintf("step %d (%g)", i+1, s.levels[i])
+}
+
+func (s *steps) Duration() time.Duration {
+	return s.d * time.Duration(len(s.levels))
+}
+
+type spikeProfile struct {
+	base  float64
+	peak  float64
+	d     time.Duration
+	at    time.Duration
+	spike time.Duration
+}
+
+func (s *spikeProfile) Target(t time.Duration) float64 {
+	if t >= s.at && t < s.at+s.spike {
+		return s.peak
+	}
+
+	return s.base
+}
+
+func (s *spikeProfile) Stage(t time.Duration) string {
+	if t < s.at {
+		return "base"
+	} else if t < s.at+s.spike {
+		return "spike"
+	} else {
+		return "recovery"
+	}
+}
+
+func (s *spikeProfile) Duration() time.Duration {
+	return s.d
+}
+
+type sine struct {
+	mean      float64
+	amplitude float64
+	period    time.Duration
+	d         time.Duration
+}
+
+func (s *sine) Target(t time.Duration) float64 {
+	v := s.mean + s.amplitude*math.Sin(2*math.Pi*float64(t)/float64(s.period))
+	if v < 0 {
+		return 0
+	}
+
+	return v
+}
+
+func (s *sine) Stage(t time.Duration) string {
+	return fmt.Sprintf("period %d", int(t/s.period)+1)
+}
+
+func (s *sine) Duration() time.Duration {
+	return s.d
+}
@@ -0,0 +1,136 @@
+package antpost
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Progress watches a run while it is live. Add it to the run with
+// WithWatcher, then poll Snapshot() or Subscribe().
+type Progress struct {
+	lock           sync.Mutex
+	begin          time.Time
+	n              int
+	ok             int
+	connectFail    int
+	responseBroken int
+	checkFail      int
+	recent         slidingWindow
+}
+
+type ProgressReport struct {
+	Elapsed        time.Duration
+	N              int
+	OK             int
+	ConnectFail    int
+	ResponseBroken int
+	CheckFail      int
+	Errors         int
+	RPS            float64
+
+	// over the last Window only
+	Window    time.Duration
+	RecentRPS float64
+	Recent    *DurationReport
+}
+
+// NewProgress keeps latencies of the last window for rolling percentiles.
+func NewProgress(window time.Duration) *Progress {
+	p := new(Progress)
+	p.recent = slidingWindow{d: window}
+	return p
+}
+
+func (p *Progress) Watch(it *Iteration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.begin.IsZero() || it.Start.Before(p.begin) {
+		p.begin = it.Start
+	}
+
+	p.n++
+	switch it.Result {
+	case ResultOK:
+		p.ok++
+	case ResultConnectFail:
+		p.connectFail++
+	case ResultResponseBroken:
+		p.responseBroken++
+	case ResultCheckFail:
+		p.checkFail++
+	}
+
+	p.recent.add(it, nil)
+}
+
+func (p *Progress) Snapshot() *ProgressReport {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	p.recent.prune(now, nil)
+
+	r := new(ProgressReport)
+	if !p.begin.IsZero() {
+		r.Elapsed = now.Sub(p.begin)
+	}
+
+	r.N = p.n
+	r.OK = p.ok
+	r.ConnectFail = p.connectFail
+	r.ResponseBroken = p.responseBroken
+	r.CheckFail = p.checkFail
+	r.Errors = p.n - p.ok
+	if r.Elapsed > 0 {
+		r.RPS = float64(r.N) / r.Elapsed.Seconds()
+	}
+
+	window := p.recent.d
+	if r.Elapsed < window {
+		window = r.Elapsed
+	}
+
+	d := make([]time.Duration, 0, p.recent.len())
+	for _, e := range p.recent.window() {
+		d = append(d, e.time)
+	}
+
+	r.Window = window
+	if window > 0 {
+		r.RecentRPS = float64(len(d)) / window.Seconds()
+	}
+
+	r.Recent = AnalyzeDurationReport(d)
+	return r
+}
+
+// Subscribe sends a snapshot every interval until ctx is done, then closes
+// the channel. Snapshots are skipped while the receiver is not ready.
+func (p *Progress) Subscribe(ctx context.Context, every time.Duration) <-chan *ProgressReport {
+	c := make(chan *ProgressReport, 1)
+	go func() {
+		defer close(c)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case c <- p.Snapshot():
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+func (r *ProgressReport) String() string {
+	return fmt.Sprintf("%v: n %7d (%.2f/s),  errors %7d (connect %d, broken %d, check %d),  last %v: %.2f/s, %v", r.Elapsed, r.N, r.RPS, r.Errors, r.ConnectFail, r.ResponseBroken, r.CheckFail, r.Window, r.RecentRPS, r.Recent)
+}
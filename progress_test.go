@@ -0,0 +1,45 @@
+package antpost
+
+import "testing"
+
+import (
+	"context"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	p := NewProgress(time.Second)
+	ctx, cancel := context.WithCancel(WithWatcher(context.Background(), p))
+	defer cancel()
+
+	updates := p.Subscribe(ctx, 20*time.Millisecond)
+	done := make(chan *Context)
+	go func() {
+		done <- RunTotalContext(ctx, &sleepDrone{time.Millisecond}, 4, 200, 0)
+	}()
+
+	live := 0
+	var c *Context
+	for c == nil {
+		select {
+		case r := <-updates:
+			if r.N > 0 {
+				live++
+			}
+		case c = <-done:
+		}
+	}
+
+	if live == 0 {
+		t.Errorf("Progress.Subscribe() gave no live snapshot")
+	}
+
+	r := p.Snapshot()
+	if r.N != 200 || r.OK != 200 || r.Errors != 0 || r.Recent.N != 200 {
+		t.Errorf("Progress.Snapshot() => %v", r)
+	}
+
+	if n := c.Report().Time.N; n != 200 {
+		t.Errorf("Report() after Progress n %d != 200", n)
+	}
+}
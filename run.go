@@ -1,11 +1,19 @@
 package antpost
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
 func Run(drone Drone, goroutines int, count int, d time.Duration) *Context {
+	return RunContext(context.Background(), drone, goroutines, count, d)
+}
+
+// RunContext is Run that stops when ctx is done. Requests in flight are
+// aborted by drones that honor Context.Context(), and the iterations that
+// did finish are still returned.
+func RunContext(ctx context.Context, drone Drone, goroutines int, count int, d time.Duration) *Context {
 	if goroutines <= 0 {
 		return nil
 	}
@@ -15,6 +23,8 @@ func Run(drone Drone, goroutines int, count int, d time.Duration) *Context {
 	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
 		context := NewContext()
+		context.SetContext(ctx)
+		context.worker = i + 1
 		if count > 0 {
 			context.SetCount(count)
 		}
@@ -32,11 +42,178 @@ func Run(drone Drone, goroutines int, count int, d time.Duration) *Context {
 
 	wg.Wait()
 	contexts[0].Combine(contexts[1:]...)
+	contexts[0].canceled = ctx.Err() != nil
+	return contexts[0]
+}
+
+// RunTotal is Run with count as the total number of iterations shared by
+// all goroutines, rather than the number each goroutine runs.
+func RunTotal(drone Drone, goroutines int, total int, d time.Duration) *Context {
+	return RunTotalContext(context.Background(), drone, goroutines, total, d)
+}
+
+func RunTotalContext(ctx context.Context, drone Drone, goroutines int, total int, d time.Duration) *Context {
+	if goroutines <= 0 || total <= 0 {
+		return nil
+	}
+
+	budget := int64(total)
+	contexts := make([]*Context, 0, goroutines)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		context := NewContext()
+		context.SetContext(ctx)
+		context.worker = i + 1
+		context.budget = &budget
+		if d > 0 {
+			context.SetTime(d)
+		}
+
+		contexts = append(contexts, context)
+		go func(d Drone, c *Context) {
+			defer wg.Done()
+			run(d, c)
+		}(drone.Next(), context)
+	}
+
+	wg.Wait()
+	contexts[0].Combine(contexts[1:]...)
+	contexts[0].canceled = ctx.Err() != nil
 	return contexts[0]
 }
 
 func run(drone Drone, context *Context) {
+	defer context.release()
+
+	for drone != nil {
+		if !context.Start() {
+			return
+		}
+
+		result := drone.Run(context)
+		context.End(result)
+
+		drone = drone.Next()
+	}
+}
+
+// RunRate runs drone open-loop: arrivals are issued at rate per second to a
+// pool of workers, whether or not earlier ones have returned. Each iteration
+// starts at its intended arrival time, so a slow target shows up as latency
+// instead of as a lower offered load. The run ends after count arrivals or
+// after d, whichever comes first.
+func RunRate(drone Drone, workers int, rate float64, count int, d time.Duration) *Context {
+	return RunRateContext(context.Background(), drone, workers, rate, count, d)
+}
+
+func RunRateContext(ctx context.Context, drone Drone, workers int, rate float64, count int, d time.Duration) *Context {
+	if workers <= 0 || rate <= 0 {
+		return nil
+	}
+
+	c := runArrivals(ctx, drone, workers, func(time.Duration) float64 { return rate }, nil, count, d)
+	c.arrivals.rate = rate
+	return c
+}
+
+// RunProfile runs drone closed-loop with as many goroutines as profile
+// targets at each moment, adding and removing them while the run is live.
+// Iterations are reported by profile stage. It is nil if profile is.
+func RunProfile(drone Drone, profile LoadProfile) *Context {
+	return RunProfileContext(context.Background(), drone, profile)
+}
+
+func RunProfileContext(ctx context.Context, drone Drone, profile LoadProfile) *Context {
+	if profile == nil {
+		return nil
+	}
+
+	begin := time.Now()
+	d := profile.Duration()
+
+	contexts := make([]*Context, 0)
+	stops := make([]chan bool, 0)
+	wg := new(sync.WaitGroup)
+	ticker := time.NewTicker(profileTick)
+	defer ticker.Stop()
+	for elapsed := time.Duration(0); elapsed < d && ctx.Err() == nil; elapsed = time.Now().Sub(begin) {
+		target := int(profile.Target(elapsed) + 0.5)
+		for len(stops) < target {
+			wg.Add(1)
+			context := NewContext()
+			context.SetContext(ctx)
+			context.SetTime(d - elapsed)
+			context.worker = len(contexts) + 1
+			stop := make(chan bool)
+			contexts = append(contexts, context)
+			stops = append(stops, stop)
+			go func(d Drone, c *Context, stop <-chan bool) {
+				defer wg.Done()
+				runProfile(d, c, profile, begin, stop)
+			}(drone.Next(), context, stop)
+		}
+
+		for len(stops) > target && len(stops) > 0 {
+			close(stops[len(stops)-1])
+			stops = stops[:len(stops)-1]
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+
+	for _, stop := range stops {
+		close(stop)
+	}
+
+	wg.Wait()
+	if len(contexts) == 0 {
+		contexts = append(contexts, NewContext())
+	}
+
+	contexts[0].Combine(contexts[1:]...)
+	contexts[0].canceled = ctx.Err() != nil
+	return contexts[0]
+}
+
+// RunRateProfile is RunRate with the arrival rate taken from profile.
+func RunRateProfile(drone Drone, workers int, profile LoadProfile) *Context {
+	return RunRateProfileContext(context.Background(), drone, workers, profile)
+}
+
+func RunRateProfileContext(ctx context.Context, drone Drone, workers int, profile LoadProfile) *Context {
+	if profile == nil {
+		return nil
+	}
+
+	d := profile.Duration()
+	if workers <= 0 || d <= 0 {
+		return nil
+	}
+
+	c := runArrivals(ctx, drone, workers, profile.Target, profile, 0, d)
+	c.arrivals.rate = float64(c.arrivals.scheduled) / d.Seconds()
+	return c
+}
+
+// profileTick is how often profiles are sampled, and how long a rate
+// profile waits while its rate is zero.
+const profileTick = 100 * time.Millisecond
+
+func runProfile(drone Drone, context *Context, profile LoadProfile, begin time.Time, stop <-chan bool) {
+	defer context.release()
+
 	for drone != nil {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		context.stage = profile.Stage(time.Now().Sub(begin))
 		if !context.Start() {
 			return
 		}
@@ -47,3 +224,163 @@ func run(drone Drone, context *Context) {
 		drone = drone.Next()
 	}
 }
+
+type arrival struct {
+	at    time.Time
+	stage string
+}
+
+func runArrivals(ctx context.Context, drone Drone, workers int, rate func(time.Duration) float64, profile LoadProfile, count int, d time.Duration) *Context {
+	arrivals := make(chan arrival)
+	done := make(chan bool)
+	contexts := make([]*Context, 0, workers)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		context := NewContext()
+		context.SetContext(ctx)
+		context.worker = i + 1
+		contexts = append(contexts, context)
+		go func(d Drone, c *Context) {
+			defer wg.Done()
+			runArrival(d, c, arrivals)
+		}(drone.Next(), context)
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	a := dispatch(ctx, arrivals, done, rate, profile, count, d)
+	<-done
+	contexts[0].Combine(contexts[1:]...)
+	contexts[0].arrivals = a
+	contexts[0].canceled = ctx.Err() != nil
+	return contexts[0]
+}
+
+func runArrival(drone Drone, context *Context, arrivals <-chan arrival) {
+	defer context.release()
+
+	for drone != nil {
+		a, ok := <-arrivals
+		if !ok {
+			return
+		}
+
+		context.stage = a.stage
+		if !context.StartAt(a.at) {
+			return
+		}
+
+		result := drone.Run(context)
+		context.End(result)
+
+		drone = drone.Next()
+	}
+}
+
+// arrivalSchedule walks the offsets of arrivals issued at rate, skipping
+// ahead by profileTick while rate is zero.
+type arrivalSchedule struct {
+	rate   func(time.Duration) float64
+	count  int
+	d      time.Duration
+	i      int
+	offset time.Duration
+}
+
+func (s *arrivalSchedule) next() (time.Duration, bool) {
+	for s.count <= 0 || s.i < s.count {
+		if s.d > 0 && s.offset >= s.d {
+			return 0, false
+		}
+
+		offset := s.offset
+		rate := s.rate(offset)
+		if rate <= 0 {
+			s.offset += profileTick
+			continue
+		}
+
+		s.i++
+		s.offset += time.Duration(float64(time.Second) / rate)
+		return offset, true
+	}
+
+	return 0, false
+}
+
+// rest counts the arrivals left that were due by until.
+func (s *arrivalSchedule) rest(until time.Duration) int {
+	n := 0
+	for offset, ok := s.next(); ok && offset <= until; offset, ok = s.next() {
+		n++
+	}
+
+	return n
+}
+
+func dispatch(ctx context.Context, arrivals chan<- arrival, done <-chan bool, rate func(time.Duration) float64, profile LoadProfile, count int, d time.Duration) *arrivalStat {
+	defer close(arrivals)
+
+	a := new(arrivalStat)
+
+	var deadline <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	begin := time.Now()
+	s := &arrivalSchedule{rate: rate, count: count, d: d}
+	for offset, ok := s.next(); ok; offset, ok = s.next() {
+		next := arrival{at: begin.Add(offset)}
+		if profile != nil {
+			next.stage = profile.Stage(offset)
+		}
+
+		a.scheduled++
+		if wait := next.at.Sub(time.Now()); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-deadline:
+				a.scheduled--
+				return a
+			case <-done:
+				return a
+			case <-ctx.Done():
+				a.scheduled--
+				return a
+			}
+		}
+
+		select {
+		case arrivals <- next:
+			a.issued++
+			continue
+		default:
+		}
+
+		a.delayed++
+		select {
+		case arrivals <- next:
+			a.issued++
+			if lag := time.Now().Sub(next.at); lag > a.maxLag {
+				a.maxLag = lag
+			}
+		case <-deadline:
+			a.scheduled += s.rest(time.Now().Sub(begin))
+			return a
+		case <-done:
+			return a
+		case <-ctx.Done():
+			a.scheduled += s.rest(time.Now().Sub(begin))
+			return a
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,161 @@
+package antpost
+
+import "testing"
+
+import (
+	"context"
+	"time"
+)
+
+type sleepDrone struct {
+	d time.Duration
+}
+
+func (s *sleepDrone) Run(context *Context) DroneResult {
+	time.Sleep(s.d)
+	context.Step(StepConnected)
+	context.Step(StepResponsed)
+	return ResultOK
+}
+
+func (s *sleepDrone) Next() Drone {
+	return s
+}
+
+func TestRunRate(t *testing.T) {
+	c := RunRate(&sleepDrone{time.Millisecond}, 4, 200, 20, 0)
+	if len(c.history) != 20 {
+		t.Errorf("RunRate() history %d != 20", len(c.history))
+	}
+
+	r := c.Report()
+	if r.Arrivals == nil || r.Arrivals.Issued != 20 || r.Arrivals.Dropped != 0 {
+		t.Errorf("RunRate() arrivals: %v", r.Arrivals)
+	}
+}
+
+func TestRunRateSaturated(t *testing.T) {
+	c := RunRate(&sleepDrone{50 * time.Millisecond}, 1, 100, 0, 200*time.Millisecond)
+	r := c.Report()
+	a := r.Arrivals
+	if a.Scheduled != 20 || a.Delayed == 0 || a.Dropped == 0 || a.Issued+a.Dropped != a.Scheduled {
+		t.Errorf("RunRate() saturated arrivals: %v", a)
+	}
+
+	if r.Time.P95 < 100*time.Millisecond {
+		t.Errorf("RunRate() saturated should count queueing delay, got %v", r.Time)
+	}
+}
+
+func TestRunRateSaturatedCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	c := RunRateContext(ctx, &sleepDrone{50 * time.Millisecond}, 1, 100, 0, time.Second)
+	a := c.Report().Arrivals
+	if a.Scheduled < 15 || a.Scheduled > 30 || a.Dropped == 0 || a.Issued+a.Dropped != a.Scheduled {
+		t.Errorf("RunRate() saturated and canceled arrivals: %v", a)
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	c := RunProfile(&sleepDrone{10 * time.Millisecond}, NewSteps(200*time.Millisecond, 1, 4))
+	r := c.Report()
+	if len(r.Stages) != 2 {
+		t.Errorf("RunProfile() stages: %v", r.Stages)
+		return
+	}
+
+	if r.Stages[0].Name != "step 1 (1)" || r.Stages[1].Name != "step 2 (4)" {
+		t.Errorf("RunProfile() stage names: %s, %s", r.Stages[0].Name, r.Stages[1].Name)
+	}
+
+	if r.Stages[1].Time.N < r.Stages[0].Time.N*2 {
+		t.Errorf("RunProfile() should add workers, n %d then %d", r.Stages[0].Time.N, r.Stages[1].Time.N)
+	}
+}
+
+func TestRunRateProfile(t *testing.T) {
+	c := RunRateProfile(&sleepDrone{time.Millisecond}, 4, NewSpike(50, 200, 300*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond))
+	r := c.Report()
+	if len(r.Stages) != 3 || r.Arrivals.Scheduled != 30 {
+		t.Errorf("RunRateProfile() stages %d, arrivals %v", len(r.Stages), r.Arrivals)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	ramp := NewLinearRamp(0, 10, 10*time.Second)
+	if v := ramp.Target(5 * time.Second); v != 5 {
+		t.Errorf("ramp.Target(5s) => %v != 5", v)
+	}
+
+	stairs := NewStaircase(1, 2, 3, time.Second)
+	if v := stairs.Target(2500 * time.Millisecond); v != 5 {
+		t.Errorf("stairs.Target(2.5s) => %v != 5", v)
+	}
+
+	if d := stairs.Duration(); d != 3*time.Second {
+		t.Errorf("stairs.Duration() => %v != 3s", d)
+	}
+
+	sine := NewSine(10, 5, 4*time.Second, 8*time.Second)
+	if v := sine.Target(time.Second); !isFloat64Approach(v, 15, 15) {
+		t.Errorf("sine.Target(1s) => %v != 15", v)
+	}
+
+	if s := sine.Stage(5 * time.Second); s != "period 2" {
+		t.Errorf("sine.Stage(5s) => %v", s)
+	}
+
+	if p := NewSteps(0, 1, 2); p != nil {
+		t.Errorf("NewSteps(0) => %v, not nil", p)
+	}
+
+	if p := NewStaircase(1, 2, 3, -time.Second); p != nil {
+		t.Errorf("NewStaircase(-1s) => %v, not nil", p)
+	}
+
+	if p := NewSine(10, 5, 0, 8*time.Second); p != nil {
+		t.Errorf("NewSine(period 0) => %v, not nil", p)
+	}
+
+	if c := RunProfile(&sleepDrone{time.Millisecond}, NewSteps(0, 1)); c != nil {
+		t.Errorf("RunProfile(nil) => %v, not nil", c)
+	}
+
+	if c := RunRateProfile(&sleepDrone{time.Millisecond}, 1, NewSine(10, 5, 0, time.Second)); c != nil {
+		t.Errorf("RunRateProfile(nil) => %v, not nil", c)
+	}
+}
+
+func TestRunTotal(t *testing.T) {
+	c := RunTotal(&sleepDrone{time.Millisecond}, 8, 100, 0)
+	if len(c.history) != 100 {
+		t.Errorf("RunTotal() history %d != 100", len(c.history))
+	}
+
+	r := c.Report()
+	n := 0
+	for _, w := range r.Workers {
+		n += w.N
+	}
+
+	if len(r.Workers) != 8 || n != 100 {
+		t.Errorf("RunTotal() workers %d, n %d", len(r.Workers), n)
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The last iterations end after the cancel, without another Start to
+	// notice it.
+	if r := RunContext(ctx, &sleepDrone{100 * time.Millisecond}, 2, 1, 0).Report(); !r.Canceled {
+		t.Errorf("RunContext() canceled: %v", r.Canceled)
+	}
+
+	if r := RunTotalContext(ctx, &sleepDrone{time.Millisecond}, 2, 2, 0).Report(); !r.Canceled {
+		t.Errorf("RunTotalContext() canceled: %v", r.Canceled)
+	}
+}
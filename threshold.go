@@ -0,0 +1,185 @@
+package antpost
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is a condition a metric of a Report must meet, such as
+// "Time.P95 < 200ms" or "Summary.ErrorRate <= 1%".
+type Threshold struct {
+	Path    string // of a Metric
+	Op      string // <, <=, >, >=, == or !=
+	Value   float64
+	Percent bool // Value is in percent, of a fraction or a percent metric
+}
+
+var thresholdOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// ParseThreshold parses "path op value". value is a number, a duration
+// like 200ms, or a percent like 1%.
+func ParseThreshold(s string) (*Threshold, error) {
+	for _, op := range thresholdOps {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+
+		t := &Threshold{Path: strings.TrimSpace(s[:i]), Op: op}
+		if t.Path == "" {
+			return nil, fmt.Errorf("threshold %q has no metric", s)
+		}
+
+		v := strings.TrimSpace(s[i+len(op):])
+		if strings.HasSuffix(v, "%") {
+			t.Percent = true
+			v = strings.TrimSpace(v[:len(v)-1])
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil && !t.Percent {
+			d, derr := time.ParseDuration(v)
+			if derr == nil {
+				f, err = float64(d), nil
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("threshold %q has bad value %q", s, v)
+		}
+
+		t.Value = f
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("threshold %q has no operator", s)
+}
+
+func (t *Threshold) String() string {
+	if t.Percent {
+		return fmt.Sprintf("%s %s %g%%", t.Path, t.Op, t.Value)
+	}
+
+	return fmt.Sprintf("%s %s %g", t.Path, t.Op, t.Value)
+}
+
+// value is Value in the unit of metric m.
+func (t *Threshold) value(m *Metric) (float64, error) {
+	if !t.Percent {
+		return t.Value, nil
+	}
+
+	switch m.Kind {
+	case MetricFraction:
+		return t.Value / 100, nil
+	case MetricPercent:
+		return t.Value, nil
+	default:
+		return 0, fmt.Errorf("%s is not a rate or percent", m.Path)
+	}
+}
+
+func (t *Threshold) holds(v, limit float64) bool {
+	switch t.Op {
+	case "<":
+		return v < limit
+	case "<=":
+		return v <= limit
+	case ">":
+		return v > limit
+	case ">=":
+		return v >= limit
+	case "==":
+		return v == limit
+	case "!=":
+		return v != limit
+	default:
+		return false
+	}
+}
+
+type ThresholdResult struct {
+	Threshold *Threshold
+	Metric    *Metric // nil if the report has no such metric
+	Pass      bool
+	Message   string
+}
+
+type ThresholdReport struct {
+	Pass    bool
+	Results []*ThresholdResult
+}
+
+// CheckThresholds checks every threshold against r.
+func CheckThresholds(r *Report, thresholds ...*Threshold) *ThresholdReport {
+	metrics := make(map[string]*Metric)
+	for _, m := range r.Metrics() {
+		metrics[m.Path] = m
+	}
+
+	report := &ThresholdReport{true, make([]*ThresholdResult, 0, len(thresholds))}
+	for _, t := range thresholds {
+		result := checkThreshold(metrics[t.Path], t)
+		if !result.Pass {
+			report.Pass = false
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report
+}
+
+func checkThreshold(m *Metric, t *Threshold) *ThresholdResult {
+	r := &ThresholdResult{Threshold: t, Metric: m}
+	if m == nil {
+		r.Message = fmt.Sprintf("%s: no such metric", t.Path)
+		return r
+	}
+
+	limit, err := t.value(m)
+	if err != nil {
+		r.Message = err.Error()
+		return r
+	}
+
+	r.Pass = t.holds(m.Value, limit)
+	if r.Pass {
+		r.Message = fmt.Sprintf("%s = %s, %s %s", m.Path, m.Kind.format(m.Value), t.Op, m.Kind.format(limit))
+	} else {
+		r.Message = fmt.Sprintf("%s = %s, want %s %s", m.Path, m.Kind.format(m.Value), t.Op, m.Kind.format(limit))
+	}
+
+	return r
+}
+
+func (r *ThresholdReport) String() string {
+	s := make([]string, 0, len(r.Results))
+	for _, result := range r.Results {
+		if result.Pass {
+			s = append(s, "pass  "+result.Message)
+		} else {
+			s = append(s, "FAIL  "+result.Message)
+		}
+	}
+
+	return strings.Join(s, "\n") + "\n"
+}
+
+// Err is nil if every threshold passed, or else lists those that failed.
+func (r *ThresholdReport) Err() error {
+	if r.Pass {
+		return nil
+	}
+
+	failed := make([]string, 0)
+	for _, result := range r.Results {
+		if !result.Pass {
+			failed = append(failed, result.Message)
+		}
+	}
+
+	return fmt.Errorf("thresholds failed: %s", strings.Join(failed, "; "))
+}
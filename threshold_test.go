@@ -0,0 +1,67 @@
+package antpost
+
+import "testing"
+
+import (
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	for s, want := range map[string]Threshold{
+		"Time.P95 < 200ms":                     {"Time.P95", "<", float64(200 * time.Millisecond), false},
+		"Summary.ErrorRate<=1%":                {"Summary.ErrorRate", "<=", 1, true},
+		"Summary.RPS >= 500":                   {"Summary.RPS", ">=", 500, false},
+		"Subs.login.Durations.auth.P95 != 1e6": {"Subs.login.Durations.auth.P95", "!=", 1e6, false},
+	} {
+		th, err := ParseThreshold(s)
+		if err != nil || *th != want {
+			t.Errorf("ParseThreshold(%q) => %v, %v", s, th, err)
+		}
+	}
+
+	for _, s := range []string{"Time.P95", "< 1", "Time.P95 < fast", "Time.P95 < 1ms%"} {
+		if _, err := ParseThreshold(s); err == nil {
+			t.Errorf("ParseThreshold(%q) should fail", s)
+		}
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	c := NewContext()
+	begin := time.Now()
+	for i := 1; i <= 100; i++ {
+		result := ResultOK
+		if i%50 == 0 {
+			result = ResultConnectFail
+		}
+
+		c.history = append(c.history, &droneContext{start: begin, end: begin.Add(time.Duration(i) * time.Millisecond), result: result})
+		c.stat.Sub("login").Duration("auth", time.Duration(i)*time.Millisecond)
+	}
+
+	parse := func(s string) *Threshold {
+		th, err := ParseThreshold(s)
+		if err != nil {
+			t.Fatalf("ParseThreshold(%q) failed: %v", s, err)
+		}
+
+		return th
+	}
+
+	r := c.Report()
+	pass := CheckThresholds(r, parse("Time.P95 < 200ms"), parse("Summary.ErrorRate <= 2%"), parse("Subs.login.Durations.auth.P50 < 51ms"))
+	if !pass.Pass || pass.Err() != nil {
+		t.Errorf("CheckThresholds() should pass: %v", pass)
+	}
+
+	fail := CheckThresholds(r, parse("Time.P95 < 50ms"), parse("Summary.ErrorRate < 1%"), parse("Nope.P95 < 1s"), parse("Time.P95 < 1%"), parse("Summary.RPS > 0"))
+	if fail.Pass || fail.Err() == nil {
+		t.Errorf("CheckThresholds() should fail: %v", fail)
+	}
+
+	for i, pass := range []bool{false, false, false, false, true} {
+		if fail.Results[i].Pass != pass {
+			t.Errorf("CheckThresholds() result %d: %v", i, fail.Results[i].Message)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package antpost
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TimelineBucket struct {
+	Start          time.Duration // since the run started
+	N              int
+	RPS            float64
+	OK             int
+	ConnectFail    int
+	ResponseBroken int
+	CheckFail      int
+	Time           *DurationReport
+}
+
+// TimelineReport splits iterations into buckets by the time they ended.
+type TimelineReport struct {
+	Bucket  time.Duration
+	Buckets []*TimelineBucket
+}
+
+// Timeline reports the history in buckets of the given length.
+func (c *Context) Timeline(bucket time.Duration) *TimelineReport {
+	r := &TimelineReport{bucket, make([]*TimelineBucket, 0)}
+	if len(c.history) == 0 || bucket <= 0 {
+		return r
+	}
+
+	begin := c.history[0].start
+	last := c.history[0].end
+	for _, h := range c.history {
+		if h.start.Before(begin) {
+			begin = h.start
+		}
+
+		if h.end.After(last) {
+			last = h.end
+		}
+	}
+
+	n := int(last.Sub(begin)/bucket) + 1
+	d := make([][]time.Duration, n)
+	for i := 0; i < n; i++ {
+		r.Buckets = append(r.Buckets, &TimelineBucket{Start: time.Duration(i) * bucket})
+		d[i] = make([]time.Duration, 0)
+	}
+
+	for _, h := range c.history {
+		i := int(h.end.Sub(begin) / bucket)
+		b := r.Buckets[i]
+		b.N++
+		switch h.result {
+		case ResultOK:
+			b.OK++
+		case ResultConnectFail:
+			b.ConnectFail++
+		case ResultResponseBroken:
+			b.ResponseBroken++
+		case ResultCheckFail:
+			b.CheckFail++
+		}
+
+		d[i] = append(d[i], h.end.Sub(h.start))
+	}
+
+	for i, b := range r.Buckets {
+		// The last bucket spans until the last end, but at least half a
+		// bucket, lest a few iterations just past a bucket border make a
+		// burst.
+		span := bucket
+		if i == n-1 {
+			span = last.Sub(begin) - b.Start
+			if span < bucket/2 {
+				span = bucket / 2
+			}
+		}
+
+		b.RPS = float64(b.N) / span.Seconds()
+		b.Time = c.analyze(d[i])
+	}
+
+	return r
+}
+
+func (t *TimelineReport) String() string {
+	r := make([]string, 0, len(t.Buckets)+1)
+	r = append(r, fmt.Sprintf("bucket %v", t.Bucket))
+	for _, b := range t.Buckets {
+		r = append(r, b.String())
+	}
+
+	return strings.Join(r, "\n") + "\n"
+}
+
+func (b *TimelineBucket) String() string {
+	return fmt.Sprintf("%8v: n %7d (%9.2f/s),  ok %7d,  connect fail %5d,  broken %5d,  check fail %5d,  %v", b.Start, b.N, b.RPS, b.OK, b.ConnectFail, b.ResponseBroken, b.CheckFail, b.Time)
+}
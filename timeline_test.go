@@ -0,0 +1,45 @@
+package antpost
+
+import "testing"
+
+import (
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	c := NewContext()
+	begin := time.Now()
+	for i, result := range []DroneResult{ResultOK, ResultOK, ResultConnectFail, ResultOK, ResultResponseBroken} {
+		start := begin.Add(time.Duration(i) * 400 * time.Millisecond)
+		c.history = append(c.history, &droneContext{start: start, end: start.Add(100 * time.Millisecond), step: StepResponsed, result: result})
+	}
+
+	r := c.Timeline(time.Second)
+	if len(r.Buckets) != 2 {
+		t.Errorf("Timeline() buckets %d != 2", len(r.Buckets))
+		return
+	}
+
+	b := r.Buckets[0]
+	if b.N != 3 || b.OK != 2 || b.ConnectFail != 1 || b.RPS != 3 || b.Time.Avg != 100*time.Millisecond {
+		t.Errorf("Timeline() bucket 0: %v", b)
+	}
+
+	b = r.Buckets[1]
+	if b.Start != time.Second || b.N != 2 || b.ResponseBroken != 1 || b.RPS != 2/0.7 {
+		t.Errorf("Timeline() bucket 1: %v", b)
+	}
+
+	c = NewContext()
+	c.SetPercentiles(0.75)
+	for _, end := range []time.Duration{900 * time.Millisecond, 1001 * time.Millisecond} {
+		c.history = append(c.history, &droneContext{start: begin, end: begin.Add(end), step: StepResponsed, result: ResultOK})
+	}
+
+	r = c.Timeline(time.Second)
+	if len(r.Buckets) != 2 || r.Buckets[1].RPS != 2 {
+		t.Errorf("Timeline() of a short last bucket: %v", r)
+	} else if len(r.Buckets[0].Time.Percentiles) != 1 {
+		t.Errorf("Timeline() percentiles %v, want those of SetPercentiles", r.Buckets[0].Time.Percentiles)
+	}
+}
@@ -2,6 +2,7 @@ package antpost
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -13,14 +14,16 @@ const (
 	ResultOK             DroneResult = iota
 	ResultConnectFail    DroneResult = iota
 	ResultResponseBroken DroneResult = iota
+	ResultCheckFail      DroneResult = iota // responded, but failed a check of the response
 )
 
 type DroneStep int
 
 const (
-	StepInit      DroneStep = iota
-	StepConnected DroneStep = iota
-	StepResponsed DroneStep = iota
+	StepInit       DroneStep = iota
+	StepConnected  DroneStep = iota
+	StepResponsed  DroneStep = iota
+	StepHandshaked DroneStep = iota // optional, between StepConnected and StepResponsed
 )
 
 type BoolReport struct {
@@ -31,12 +34,25 @@ type BoolReport struct {
 	FalsePercent float32
 }
 
+type DurationPercentile struct {
+	Quantile float64 // in [0, 1]
+	Value    time.Duration
+}
+
 type DurationReport struct {
-	N   int
-	Avg time.Duration
-	P05 time.Duration
-	P50 time.Duration
-	P95 time.Duration
+	N     int
+	Avg   time.Duration
+	P05   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	Min   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	P999  time.Duration
+	P9999 time.Duration
+	Max   time.Duration
+
+	Percentiles []*DurationPercentile // as asked by AnalyzeDurationPercentiles
 }
 
 type NominalReportItem struct {
@@ -86,6 +102,11 @@ type IntervalReport struct {
 	StandardDeviation float64 // 标准差
 }
 
+type RatioPercentile struct {
+	Quantile float64 // in [0, 1]
+	Value    float64
+}
+
 type RatioReport struct {
 	N int
 
@@ -101,6 +122,15 @@ type RatioReport struct {
 	P50 float64
 	P75 float64
 	P95 float64
+
+	Min   float64
+	P90   float64
+	P99   float64
+	P999  float64
+	P9999 float64
+	Max   float64
+
+	Percentiles []*RatioPercentile
 }
 
 type StatReport struct {
@@ -113,14 +143,146 @@ type StatReport struct {
 	Ratios    map[string]*RatioReport
 }
 
-type Report struct {
+// ArrivalReport describes an open-loop run: arrivals are scheduled at Rate
+// per second, Delayed ones found no idle worker and waited for one, Dropped
+// ones were never issued before the run ended.
+type ArrivalReport struct {
+	Rate      float64
+	Scheduled int
+	Issued    int
+	Delayed   int
+	Dropped   int
+	MaxLag    time.Duration
+}
+
+type StageReport struct {
+	Name   string
+	Start  time.Time
 	Time   *DurationReport
 	OKTime *DurationReport
-	Stat   *StatReport
+}
+
+type WorkerReport struct {
+	Worker int
+	N      int
+	OK     int
+	Time   *DurationReport
+}
+
+// LabelReport is the iterations named Name by Context.Label.
+type LabelReport struct {
+	Name          string
+	Summary       *SummaryReport
+	Time          *DurationReport
+	OKTime        *DurationReport
+	ConnectTime   *DurationReport
+	HandshakeTime *DurationReport
+	ResponseTime  *DurationReport
+}
+
+type SummaryReport struct {
+	N              int
+	Elapsed        time.Duration // from the first start to the last end
+	RPS            float64
+	OK             int
+	ConnectFail    int
+	ResponseBroken int
+	CheckFail      int
+	ErrorRate      float64 // of N, not OK
+	BytesSent      int64   // as told by Context.Bytes
+	BytesReceived  int64
+}
+
+// ErrorReport breaks the N failed iterations down by ErrorClass, most
+// frequent first, with a few distinct messages of each class.
+type ErrorReport struct {
+	N       int
+	Classes []*ErrorClassReport
+}
+
+type ErrorClassReport struct {
+	Class   ErrorClass
+	N       int
+	Percent float32 // of all errors
+	Samples []string
+}
+
+// AbortReport says why and when an AbortCondition stopped the run.
+type AbortReport struct {
+	Reason  string
+	At      time.Time
+	Elapsed time.Duration
+}
+
+type Report struct {
+	Summary       *SummaryReport
+	Time          *DurationReport
+	OKTime        *DurationReport
+	ConnectTime   *DurationReport // start to StepConnected, or to End if never connected
+	HandshakeTime *DurationReport // StepConnected to StepHandshaked, nil if no iteration did
+	ResponseTime  *DurationReport // StepHandshaked, or else StepConnected, to StepResponsed
+	Arrivals      *ArrivalReport
+	Stages        []*StageReport
+	Workers       []*WorkerReport
+	Labels        []*LabelReport
+	Errors        *ErrorReport
+	Timeline      *TimelineReport // not part of String(), see Timeline.String()
+	Stat          *StatReport
+	Canceled      bool
+	Abort         *AbortReport
 }
 
 func (r *Report) String() string {
-	return "Time:    " + r.Time.String() + "\n" + "OKsTime: " + r.OKTime.String() + "\n" + "Stat >>>\n" + r.Stat.String()
+	s := ""
+	if r.Abort != nil {
+		s += "Aborted: " + r.Abort.String() + "\n"
+	} else if r.Canceled {
+		s += "Canceled\n"
+	}
+
+	s += "Summary: " + r.Summary.String() + "\n"
+	s += "Time:    " + r.Time.String() + "\n" + "OKsTime: " + r.OKTime.String() + "\n"
+	s += "Connect: " + r.ConnectTime.String() + "\n"
+	if r.HandshakeTime != nil {
+		s += "Handshk: " + r.HandshakeTime.String() + "\n"
+	}
+
+	s += "Respond: " + r.ResponseTime.String() + "\n"
+	if r.Arrivals != nil {
+		s += "Arrival: " + r.Arrivals.String() + "\n"
+	}
+
+	for _, stage := range r.Stages {
+		s += "Stage " + stage.Name + " >>>\n"
+		s += "    Time:    " + stage.Time.String() + "\n"
+		s += "    OKsTime: " + stage.OKTime.String() + "\n"
+	}
+
+	for _, label := range r.Labels {
+		s += "Label " + label.Name + " >>>\n"
+		s += "    Summary: " + label.Summary.String() + "\n"
+		s += "    Time:    " + label.Time.String() + "\n"
+		s += "    OKsTime: " + label.OKTime.String() + "\n"
+	}
+
+	if r.Errors != nil {
+		s += "Errors >>>\n"
+		for _, e := range r.Errors.Classes {
+			s += "    " + e.String() + "\n"
+			for _, sample := range e.Samples {
+				s += "        " + sample + "\n"
+			}
+		}
+	}
+
+	if len(r.Workers) > 0 {
+		s += "Workers >>>\n"
+		for _, w := range r.Workers {
+			s += "    " + w.String() + "\n"
+		}
+	}
+
+	return s + "Stat >>>\n" + r.Stat.String()
 }
 
 func (s *StatReport) String() string {
@@ -129,59 +291,101 @@ func (s *StatReport) String() string {
 
 func (s *StatReport) string(prefix string) []string {
 	r := make([]string, 0, len(s.Bools)+len(s.Durations))
-	for n, b := range s.Bools {
-		r = append(r, n+" \t"+b.String())
+	for _, n := range sortedKeys(s.Bools) {
+		r = append(r, n+" \t"+s.Bools[n].String())
 	}
 
-	for n, d := range s.Durations {
-		r = append(r, n+" \t"+d.String())
+	for _, n := range sortedKeys(s.Durations) {
+		r = append(r, n+" \t"+s.Durations[n].String())
 	}
 
 	rs := make([]string, 0, len(s.Subs)*4)
-	for n, s := range s.Subs {
-		rs = append(rs, n+" >>>")
-		for _, s := range s.string(prefix) {
-			rs = append(rs, prefix+s)
+	sub := func(name string, lines []string) {
+		rs = append(rs, name+" >>>")
+		for _, line := range lines {
+			rs = append(rs, prefix+line)
 		}
 	}
 
-	for n, v := range s.Nominals {
-		rs = append(rs, n+" >>>")
-		for _, s := range v.string() {
-			rs = append(rs, prefix+s)
-		}
+	for _, n := range sortedKeys(s.Subs) {
+		sub(n, s.Subs[n].string(prefix))
 	}
 
-	for n, v := range s.Ordinals {
-		rs = append(rs, n+" >>>")
-		for _, s := range v.string() {
-			rs = append(rs, prefix+s)
-		}
+	for _, n := range sortedKeys(s.Nominals) {
+		sub(n, s.Nominals[n].string())
 	}
 
-	for n, v := range s.Intervals {
-		rs = append(rs, n+" >>>")
-		for _, s := range v.string() {
-			rs = append(rs, prefix+s)
-		}
+	for _, n := range sortedKeys(s.Ordinals) {
+		sub(n, s.Ordinals[n].string())
 	}
 
-	for n, v := range s.Ratios {
-		rs = append(rs, n+" >>>")
-		for _, s := range v.string() {
-			rs = append(rs, prefix+s)
-		}
+	for _, n := range sortedKeys(s.Intervals) {
+		sub(n, s.Intervals[n].string())
+	}
+
+	for _, n := range sortedKeys(s.Ratios) {
+		sub(n, s.Ratios[n].string())
 	}
 
 	return append(r, rs...)
 }
 
+// sortedKeys returns the names of m, a map by name, in order.
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.String())
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 func (b *BoolReport) String() string {
 	return fmt.Sprintf("n %7d,  true %7d(%.2f%%),  false %7d(%.2f%%)", b.N, b.True, b.TruePercent*100, b.False, b.FalsePercent*100)
 }
 
 func (d *DurationReport) String() string {
-	return fmt.Sprintf("n %7d,  avg %v,  5%% %v,  50%% %v, 95%% %v", d.N, d.Avg, d.P05, d.P50, d.P95)
+	s := fmt.Sprintf("n %7d,  avg %v,  min %v,  5%% %v,  50%% %v, 90%% %v, 95%% %v, 99%% %v, 99.9%% %v, 99.99%% %v,  max %v", d.N, d.Avg, d.Min, d.P05, d.P50, d.P90, d.P95, d.P99, d.P999, d.P9999, d.Max)
+	for _, p := range d.Percentiles {
+		s += fmt.Sprintf(", %g%% %v", p.Quantile*100, p.Value)
+	}
+
+	return s
+}
+
+func (e *ErrorClassReport) String() string {
+	return fmt.Sprintf("%-20s: n %7d(%6.2f%%)", e.Class, e.N, e.Percent)
+}
+
+func (a *AbortReport) String() string {
+	return fmt.Sprintf("%s, after %v at %v", a.Reason, a.Elapsed, a.At.Format("15:04:05.000"))
+}
+
+func bandwidth(bytes int64, d time.Duration) string {
+	if d <= 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	return fmt.Sprintf("%d B (%.2f KB/s)", bytes, float64(bytes)/1024/d.Seconds())
+}
+
+func (s *SummaryReport) String() string {
+	r := fmt.Sprintf("n %7d in %v (%.2f/s),  ok %7d,  connect fail %7d,  broken %7d,  check fail %7d,  errors %.2f%%", s.N, s.Elapsed, s.RPS, s.OK, s.ConnectFail, s.ResponseBroken, s.CheckFail, s.ErrorRate*100)
+	if s.BytesSent > 0 || s.BytesReceived > 0 {
+		r += fmt.Sprintf(",  sent %s,  received %s", bandwidth(s.BytesSent, s.Elapsed), bandwidth(s.BytesReceived, s.Elapsed))
+	}
+
+	return r
+}
+
+func (a *ArrivalReport) String() string {
+	return fmt.Sprintf("rate %.2f/s,  scheduled %7d,  issued %7d,  delayed %7d,  dropped %7d,  max lag %v", a.Rate, a.Scheduled, a.Issued, a.Delayed, a.Dropped, a.MaxLag)
+}
+
+func (w *WorkerReport) String() string {
+	return fmt.Sprintf("%5d: ok %7d,  %v", w.Worker, w.OK, w.Time)
 }
 
 func AnalyzeBoolReport(values []bool) *BoolReport {
@@ -201,33 +405,48 @@ func AnalyzeBoolReport(values []bool) *BoolReport {
 }
 
 func AnalyzeDurationReport(times []time.Duration) *DurationReport {
+	return AnalyzeDurationPercentiles(times)
+}
+
+// AnalyzeDurationPercentiles is AnalyzeDurationReport that also reports
+// the given quantiles, each in [0, 1], as Percentiles.
+func AnalyzeDurationPercentiles(times []time.Duration, quantiles ...float64) *DurationReport {
 	n := len(times)
 	if n <= 0 {
 		return &DurationReport{N: 0}
 	}
 
-	d := make([]int, len(times))
+	d := make([]float64, n)
+	var sum int64 = 0
 	for i, t := range times {
-		d[i] = int(t / time.Microsecond)
+		d[i] = float64(t)
+		sum += int64(t)
 	}
 
-	sort.Sort(sort.IntSlice(d))
-
-	var sum int64 = 0
-	for _, d := range d {
-		sum += int64(d)
+	sort.Float64s(d)
+	p := func(q float64) time.Duration {
+		return time.Duration(calcPercentile(d, q))
 	}
 
-	p05 := time.Duration(d[n*5/100]) * time.Microsecond
-	p50 := time.Duration(d[n*50/100]) * time.Microsecond
-	p95 := time.Duration(d[n*95/100]) * time.Microsecond
-
 	r := new(DurationReport)
 	r.N = n
-	r.Avg = time.Microsecond * time.Duration(sum/int64(n))
-	r.P05 = p05
-	r.P50 = p50
-	r.P95 = p95
+	r.Avg = time.Duration(sum / int64(n))
+	r.Min = time.Duration(d[0])
+	r.P05 = p(0.05)
+	r.P50 = p(0.50)
+	r.P90 = p(0.90)
+	r.P95 = p(0.95)
+	r.P99 = p(0.99)
+	r.P999 = p(0.999)
+	r.P9999 = p(0.9999)
+	r.Max = time.Duration(d[n-1])
+	if len(quantiles) > 0 {
+		r.Percentiles = make([]*DurationPercentile, 0, len(quantiles))
+		for _, q := range quantiles {
+			r.Percentiles = append(r.Percentiles, &DurationPercentile{q, p(q)})
+		}
+	}
+
 	return r
 }
 
@@ -274,10 +493,18 @@ func (v *RatioReport) string() []string {
 	r = append(r, s)
 	s = fmt.Sprintf("G: %17f,  Q: %17f,  H: %17f", v.GeometricMean, v.QuadraticMean, v.HarmonicMean)
 	r = append(r, s)
-	s = fmt.Sprintf("P05: %15f,  P25: %15f,  P25: %15f", v.P05, v.P25, v.P50)
+	s = fmt.Sprintf("P05: %15f,  P25: %15f,  P50: %15f", v.P05, v.P25, v.P50)
+	r = append(r, s)
+	s = fmt.Sprintf("P75: %15f,  P90: %15f,  P95: %15f", v.P75, v.P90, v.P95)
+	r = append(r, s)
+	s = fmt.Sprintf("P99: %15f,  P99.9: %13f,  P99.99: %12f", v.P99, v.P999, v.P9999)
 	r = append(r, s)
-	s = fmt.Sprintf("P75: %15f,  P95: %15f", v.P75, v.P95)
+	s = fmt.Sprintf("min: %15f,  max: %15f", v.Min, v.Max)
 	r = append(r, s)
+	for _, p := range v.Percentiles {
+		s = fmt.Sprintf("P%g: %15f", p.Quantile*100, p.Value)
+		r = append(r, s)
+	}
 
 	return r
 }
@@ -0,0 +1,50 @@
+package antpost
+
+import (
+	"context"
+	"time"
+)
+
+// Iteration is what a Watcher sees of one finished Drone.Run.
+type Iteration struct {
+	Worker     int
+	Stage      string
+	Label      string
+	Result     DroneResult
+	Err        error // as told by Context.Error
+	Start      time.Time
+	Connected  time.Time
+	Handshaked time.Time // zero without StepHandshaked
+	Responsed  time.Time
+	End        time.Time
+}
+
+func (it *Iteration) Time() time.Duration {
+	return it.End.Sub(it.Start)
+}
+
+// Watcher is told of every iteration as soon as it ends. Watch is called
+// from all worker goroutines at once.
+type Watcher interface {
+	Watch(it *Iteration)
+}
+
+type watchersKey struct{}
+
+// WithWatcher returns a copy of ctx that makes runs started with it report
+// every iteration to w, in addition to the watchers ctx already carries.
+func WithWatcher(ctx context.Context, w Watcher) context.Context {
+	parent := watchersFrom(ctx)
+	watchers := make([]Watcher, len(parent), len(parent)+1)
+	copy(watchers, parent)
+	return context.WithValue(ctx, watchersKey{}, append(watchers, w))
+}
+
+func watchersFrom(ctx context.Context) []Watcher {
+	watchers, _ := ctx.Value(watchersKey{}).([]Watcher)
+	return watchers
+}
+
+func (c *droneContext) iteration() *Iteration {
+	return &Iteration{c.worker, c.stage, c.label, c.result, c.err, c.start, c.connected, c.handshaked, c.responsed, c.end}
+}